@@ -51,6 +51,26 @@ func GetBool(paramName string, defaultValue bool) bool {
 	return _parameters.GetBool(strings.ToLower(paramName), defaultValue)
 }
 
+func GetStrings(paramName string, defaultValue []string) []string {
+	return _parameters.GetStrings(strings.ToLower(paramName), defaultValue)
+}
+
+// Returns the parameters whose names start with the specified prefix (followed by a dot), with the prefix removed from
+// their names.
+func GetSection(prefix string) data.GenericMap {
+	var section = data.NewGenericMap()
+
+	prefix = strings.ToLower(prefix) + "."
+
+	for key, value := range _parameters {
+		if strings.HasPrefix(key, prefix) {
+			section.Set(key[len(prefix):], value)
+		}
+	}
+
+	return section
+}
+
 const (
 	_logTag string = "config"
 )
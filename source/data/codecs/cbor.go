@@ -0,0 +1,415 @@
+package codecs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"gogogo/data"
+	"io"
+	"math"
+)
+
+// The CBOR codec (RFC 8949). Decoded numbers are float64 values (like the JSON decoder ones) and byte strings are
+// []byte values. Tags are ignored (the tagged values are decoded as if they had no tag).
+type CborCodec struct {
+	Encoder
+	Decoder
+}
+
+// Creates a new CBOR codec.
+func Cbor() *CborCodec {
+	return &CborCodec{}
+}
+
+func (codec *CborCodec) Encode(value interface{}) (cborData []byte, err error) {
+	var normalizedValue interface{}
+
+	if normalizedValue, err = normalize(value); err != nil {
+		return
+	}
+
+	var buffer bytes.Buffer
+	encodeCbor(&buffer, normalizedValue)
+
+	return buffer.Bytes(), nil
+}
+
+func (codec *CborCodec) Decode(reader io.Reader, params map[string]string) (data.GenericMap, error) {
+	var binaryReader, err = newBinaryReader(reader)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var value interface{}
+
+	if value, err = decodeCbor(binaryReader); err != nil {
+		return nil, err
+	}
+
+	return decodedMap(value)
+}
+
+// The CBOR major types.
+const (
+	cborUnsigned = 0
+	cborNegative = 1
+	cborBytes    = 2
+	cborText     = 3
+	cborArray    = 4
+	cborMap      = 5
+	cborTag      = 6
+	cborSimple   = 7
+)
+
+func encodeCbor(buffer *bytes.Buffer, value interface{}) {
+	switch typedValue := value.(type) {
+	case nil:
+		buffer.WriteByte(cborSimple<<5 | 22)
+
+	case bool:
+		if typedValue {
+			buffer.WriteByte(cborSimple<<5 | 21)
+		} else {
+			buffer.WriteByte(cborSimple<<5 | 20)
+		}
+
+	case int64:
+		if typedValue >= 0 {
+			encodeCborHead(buffer, cborUnsigned, uint64(typedValue))
+		} else {
+			encodeCborHead(buffer, cborNegative, uint64(-(typedValue + 1)))
+		}
+
+	case uint64:
+		encodeCborHead(buffer, cborUnsigned, typedValue)
+
+	case float64:
+		buffer.WriteByte(cborSimple<<5 | 27)
+		buffer.Write(binary.BigEndian.AppendUint64(nil, math.Float64bits(typedValue)))
+
+	case string:
+		encodeCborHead(buffer, cborText, uint64(len(typedValue)))
+		buffer.WriteString(typedValue)
+
+	case []byte:
+		encodeCborHead(buffer, cborBytes, uint64(len(typedValue)))
+		buffer.Write(typedValue)
+
+	case []interface{}:
+		encodeCborHead(buffer, cborArray, uint64(len(typedValue)))
+
+		for _, item := range typedValue {
+			encodeCbor(buffer, item)
+		}
+
+	case []mapEntry:
+		encodeCborHead(buffer, cborMap, uint64(len(typedValue)))
+
+		for _, entry := range typedValue {
+			encodeCbor(buffer, entry.key)
+			encodeCbor(buffer, entry.value)
+		}
+
+	default:
+		panic(fmt.Sprintf("unexpected normalized type: %T", value))
+	}
+}
+
+// Writes the initial byte of a data item (major type and additional information) followed by its argument, using the
+// shortest form.
+func encodeCborHead(buffer *bytes.Buffer, majorType byte, argument uint64) {
+	switch {
+	case argument < 24:
+		buffer.WriteByte(majorType<<5 | byte(argument))
+	case argument <= math.MaxUint8:
+		buffer.Write([]byte{majorType<<5 | 24, byte(argument)})
+	case argument <= math.MaxUint16:
+		buffer.WriteByte(majorType<<5 | 25)
+		buffer.Write(binary.BigEndian.AppendUint16(nil, uint16(argument)))
+	case argument <= math.MaxUint32:
+		buffer.WriteByte(majorType<<5 | 26)
+		buffer.Write(binary.BigEndian.AppendUint32(nil, uint32(argument)))
+	default:
+		buffer.WriteByte(majorType<<5 | 27)
+		buffer.Write(binary.BigEndian.AppendUint64(nil, argument))
+	}
+}
+
+var (
+	errCborBreak = errors.New("unexpected CBOR break")
+)
+
+// The additional information value for indefinite lengths (and for the break code, with the simple values major type).
+const (
+	cborIndefinite = 31
+)
+
+func decodeCbor(reader *binaryReader) (value interface{}, err error) {
+	var header []byte
+
+	if header, err = reader.next(1); err != nil {
+		return
+	}
+
+	var majorType, additionalInfo = header[0] >> 5, header[0] & 0x1f
+
+	if additionalInfo == cborIndefinite {
+		return decodeIndefiniteCbor(reader, majorType)
+	}
+
+	if majorType == cborSimple {
+		return decodeCborSimple(reader, additionalInfo)
+	}
+
+	var argument uint64
+
+	switch {
+	case additionalInfo < 24:
+		argument = uint64(additionalInfo)
+	case additionalInfo <= 27:
+		if argument, err = reader.uint(1 << (additionalInfo - 24)); err != nil {
+			return
+		}
+	default:
+		return nil, fmt.Errorf("bad CBOR additional information: %d", additionalInfo)
+	}
+
+	switch majorType {
+	case cborUnsigned:
+		return float64(argument), nil
+	case cborNegative:
+		return -1 - float64(argument), nil
+
+	case cborBytes:
+		var bytes []byte
+
+		if bytes, err = reader.next(argument); err != nil {
+			return
+		}
+
+		return append(make([]byte, 0, len(bytes)), bytes...), nil
+
+	case cborText:
+		var bytes []byte
+
+		if bytes, err = reader.next(argument); err != nil {
+			return
+		}
+
+		return string(bytes), nil
+
+	case cborArray:
+		if err = reader.checkCount(argument); err != nil {
+			return
+		}
+
+		if err = reader.enter(); err != nil {
+			return
+		}
+
+		defer reader.leave()
+
+		var list = make([]interface{}, argument)
+
+		for index := range list {
+			if list[index], err = decodeCbor(reader); err != nil {
+				return
+			}
+		}
+
+		return list, nil
+
+	case cborMap:
+		if err = reader.checkCount(argument); err != nil {
+			return
+		}
+
+		if err = reader.enter(); err != nil {
+			return
+		}
+
+		defer reader.leave()
+
+		var mapValue = make(map[string]interface{}, argument)
+
+		for index := uint64(0); index < argument; index++ {
+			if err = decodeCborMapEntry(reader, mapValue); err != nil {
+				return
+			}
+		}
+
+		return mapValue, nil
+
+	case cborTag:
+		if err = reader.enter(); err != nil {
+			return
+		}
+
+		defer reader.leave()
+
+		return decodeCbor(reader)
+	}
+
+	return
+}
+
+func decodeCborMapEntry(reader *binaryReader, mapValue map[string]interface{}) (err error) {
+	var key, entryValue interface{}
+	var stringKey string
+
+	if key, err = decodeCbor(reader); err != nil {
+		return
+	}
+
+	if stringKey, err = mapKey(key); err != nil {
+		return
+	}
+
+	if entryValue, err = decodeCbor(reader); err != nil {
+		return
+	}
+
+	mapValue[stringKey] = entryValue
+	return
+}
+
+// Decodes the items of an indefinite length value, up to the break code.
+func decodeIndefiniteCbor(reader *binaryReader, majorType byte) (value interface{}, err error) {
+	if majorType == cborSimple {
+		return nil, errCborBreak
+	}
+
+	if err = reader.enter(); err != nil {
+		return
+	}
+
+	defer reader.leave()
+
+	var isBreak = func() bool {
+		if (reader.offset < len(reader.data)) && (reader.data[reader.offset] == cborSimple<<5|cborIndefinite) {
+			reader.offset++
+			return true
+		}
+
+		return false
+	}
+
+	switch majorType {
+	case cborBytes, cborText:
+		var chunks bytes.Buffer
+
+		for !isBreak() {
+			var chunk interface{}
+
+			if chunk, err = decodeCbor(reader); err != nil {
+				return
+			}
+
+			switch typedChunk := chunk.(type) {
+			case []byte:
+				chunks.Write(typedChunk)
+			case string:
+				chunks.WriteString(typedChunk)
+			default:
+				return nil, errors.New("bad CBOR string chunk")
+			}
+		}
+
+		if majorType == cborText {
+			return chunks.String(), nil
+		}
+
+		return chunks.Bytes(), nil
+
+	case cborArray:
+		var list = make([]interface{}, 0)
+
+		for !isBreak() {
+			var item interface{}
+
+			if item, err = decodeCbor(reader); err != nil {
+				return
+			}
+
+			list = append(list, item)
+		}
+
+		return list, nil
+
+	case cborMap:
+		var mapValue = make(map[string]interface{})
+
+		for !isBreak() {
+			if err = decodeCborMapEntry(reader, mapValue); err != nil {
+				return
+			}
+		}
+
+		return mapValue, nil
+	}
+
+	return nil, fmt.Errorf("bad CBOR indefinite length for major type %d", majorType)
+}
+
+func decodeCborSimple(reader *binaryReader, additionalInfo byte) (value interface{}, err error) {
+	var bits uint64
+
+	switch additionalInfo {
+	case 20:
+		return false, nil
+	case 21:
+		return true, nil
+	case 22, 23:
+		return nil, nil
+
+	case 25:
+		if bits, err = reader.uint(2); err != nil {
+			return
+		}
+
+		return halfFloat(uint16(bits)), nil
+
+	case 26:
+		if bits, err = reader.uint(4); err != nil {
+			return
+		}
+
+		return float64(math.Float32frombits(uint32(bits))), nil
+
+	case 27:
+		if bits, err = reader.uint(8); err != nil {
+			return
+		}
+
+		return math.Float64frombits(bits), nil
+	}
+
+	return nil, fmt.Errorf("unsupported CBOR simple value: %d", additionalInfo)
+}
+
+// Converts an IEEE 754 half precision float.
+func halfFloat(bits uint16) float64 {
+	var exponent, mantissa = int(bits>>10) & 0x1f, float64(bits & 0x3ff)
+	var value float64
+
+	switch exponent {
+	case 0:
+		value = math.Ldexp(mantissa, -24)
+	case 0x1f:
+		if mantissa == 0 {
+			value = math.Inf(1)
+		} else {
+			value = math.NaN()
+		}
+	default:
+		value = math.Ldexp(mantissa+1024, exponent-25)
+	}
+
+	if bits&0x8000 != 0 {
+		return -value
+	}
+
+	return value
+}
@@ -0,0 +1,216 @@
+package codecs
+
+import (
+	"gogogo/data"
+	"io"
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Encodes response data to a media type.
+type Encoder interface {
+	Encode(value interface{}) ([]byte, error)
+}
+
+// Decodes request data from a media type. The media type parameters (like "charset" or "boundary") are passed to the
+// decoder.
+type Decoder interface {
+	Decode(reader io.Reader, params map[string]string) (data.GenericMap, error)
+}
+
+// Registers an encoder for a media type, replacing the current one if any (encoders registered first are preferred).
+func AddEncoder(mediaType string, encoder Encoder) {
+	mediaType = strings.ToLower(mediaType)
+
+	for _, entry := range _encoders {
+		if entry.mediaType == mediaType {
+			entry.encoder = encoder
+			return
+		}
+	}
+
+	_encoders = append(_encoders, &encoderEntry{mediaType, encoder})
+}
+
+// Returns the media types there are encoders for, in the order they were registered.
+func EncoderMediaTypes() []string {
+	var mediaTypes = make([]string, 0, len(_encoders))
+
+	for _, entry := range _encoders {
+		mediaTypes = append(mediaTypes, entry.mediaType)
+	}
+
+	return mediaTypes
+}
+
+// Selects the encoder with the highest quality for an Accept header value (the first one if empty), or nil if no
+// encoder is acceptable.
+func Negotiate(accept string) (mediaType string, encoder Encoder) {
+	if len(_encoders) == 0 {
+		return
+	}
+
+	if strings.TrimSpace(accept) == "" {
+		return _encoders[0].mediaType, _encoders[0].encoder
+	}
+
+	var mediaRanges = parseAccept(accept)
+	var bestQuality = 0.0
+	var bestPosition = len(mediaRanges)
+
+	for _, entry := range _encoders {
+		var quality, position = acceptQuality(mediaRanges, entry.mediaType)
+
+		if (quality > bestQuality) || ((quality == bestQuality) && (quality > 0) && (position < bestPosition)) {
+			mediaType, encoder = entry.mediaType, entry.encoder
+			bestQuality, bestPosition = quality, position
+		}
+	}
+
+	return
+}
+
+// Registers a decoder for a media type (replacing the one registered for it, if any). JSON, form, multipart,
+// MessagePack and CBOR decoders are registered by default.
+func AddDecoder(mediaType string, decoder Decoder) {
+	_decoders[strings.ToLower(mediaType)] = decoder
+}
+
+// Returns the decoder for a Content-Type header value (which may have parameters) and the media type parameters. The
+// decoder is nil if there is no decoder for the media type or if the value is not valid.
+func FindDecoder(contentType string) (decoder Decoder, params map[string]string) {
+	var mediaType, err = "", error(nil)
+
+	if mediaType, params, err = mime.ParseMediaType(contentType); err != nil {
+		return nil, nil
+	}
+
+	return _decoders[mediaType], params
+}
+
+// Returns the media types there are decoders for, sorted.
+func DecoderMediaTypes() []string {
+	var mediaTypes = make([]string, 0, len(_decoders))
+
+	for mediaType := range _decoders {
+		mediaTypes = append(mediaTypes, mediaType)
+	}
+
+	sort.Strings(mediaTypes)
+	return mediaTypes
+}
+
+// Checks whether an Accept header value accepts a media type (with a quality above zero).
+func Accepts(accept string, mediaType string) bool {
+	var quality, _ = acceptQuality(parseAccept(accept), strings.ToLower(mediaType))
+	return quality > 0
+}
+
+type encoderEntry struct {
+	mediaType string
+	encoder   Encoder
+}
+
+var (
+	_encoders = []*encoderEntry{
+		{"application/json", Json()},
+		{"application/xml", Xml()},
+		{"text/xml", Xml()},
+		{"application/msgpack", MessagePack()},
+		{"application/x-msgpack", MessagePack()},
+		{"application/vnd.msgpack", MessagePack()},
+		{"application/cbor", Cbor()},
+	}
+
+	_decoders = map[string]Decoder{
+		"application/json":                  Json(),
+		"application/x-www-form-urlencoded": Form(),
+		"multipart/form-data":               Multipart(),
+		"application/msgpack":               MessagePack(),
+		"application/x-msgpack":             MessagePack(),
+		"application/vnd.msgpack":           MessagePack(),
+		"application/cbor":                  Cbor(),
+	}
+)
+
+// A media range from an Accept header.
+type mediaRange struct {
+	mediaType   string
+	subtype     string
+	quality     float64
+	specificity int
+}
+
+func parseAccept(accept string) []mediaRange {
+	var mediaRanges = make([]mediaRange, 0)
+
+	for _, value := range strings.Split(accept, ",") {
+		var mediaType, params, err = mime.ParseMediaType(strings.TrimSpace(value))
+
+		if err != nil {
+			// mime.ParseMediaType rejects "*" (sent by some old clients)
+			if strings.TrimSpace(value) != "*" {
+				continue
+			}
+
+			mediaType = "*/*"
+		}
+
+		var typeParts = strings.SplitN(mediaType, "/", 2)
+
+		if len(typeParts) != 2 {
+			continue
+		}
+
+		var currentRange = mediaRange{
+			mediaType: typeParts[0],
+			subtype:   typeParts[1],
+			quality:   1,
+		}
+
+		if quality, hasQuality := params["q"]; hasQuality {
+			if currentRange.quality, err = strconv.ParseFloat(quality, 64); (err != nil) || (currentRange.quality < 0) {
+				continue
+			}
+		}
+
+		if currentRange.mediaType != "*" {
+			currentRange.specificity++
+		}
+
+		if currentRange.subtype != "*" {
+			currentRange.specificity++
+		}
+
+		mediaRanges = append(mediaRanges, currentRange)
+	}
+
+	return mediaRanges
+}
+
+// Returns the quality of the most specific media range matching a media type and the position of the range (or 0 and
+// the number of ranges if no range matches).
+func acceptQuality(mediaRanges []mediaRange, mediaType string) (quality float64, position int) {
+	var typeParts = strings.SplitN(mediaType, "/", 2)
+	var specificity = -1
+
+	position = len(mediaRanges)
+
+	for rangeIndex, currentRange := range mediaRanges {
+		if (currentRange.mediaType != "*") && (currentRange.mediaType != typeParts[0]) {
+			continue
+		}
+
+		if (currentRange.subtype != "*") && (currentRange.subtype != typeParts[1]) {
+			continue
+		}
+
+		if currentRange.specificity > specificity {
+			quality, position, specificity = currentRange.quality, rangeIndex, currentRange.specificity
+		}
+	}
+
+	return
+}
@@ -0,0 +1,73 @@
+package codecs
+
+import (
+	"fmt"
+	"gogogo/data"
+	"io"
+	"net/url"
+	"strings"
+)
+
+// The decoder for URL encoded forms. Fields are decoded as strings, or as lists of strings when they are repeated.
+type FormCodec struct {
+	Decoder
+}
+
+// Creates a new form codec.
+func Form() *FormCodec {
+	return &FormCodec{}
+}
+
+func (codec *FormCodec) Decode(reader io.Reader, params map[string]string) (decodedData data.GenericMap, err error) {
+	if err = checkCharset(params); err != nil {
+		return
+	}
+
+	var formData []byte
+
+	if formData, err = io.ReadAll(reader); err != nil {
+		return
+	}
+
+	var values url.Values
+
+	if values, err = url.ParseQuery(string(formData)); err != nil {
+		return
+	}
+
+	decodedData = data.NewGenericMap()
+
+	for name, fieldValues := range values {
+		for _, value := range fieldValues {
+			addFormValue(decodedData, name, value)
+		}
+	}
+
+	return
+}
+
+// Adds a form field value, turning the field into a list when it is repeated.
+func addFormValue(formData data.GenericMap, name string, value interface{}) {
+	var currentValue, valueExists = formData[name]
+
+	if !valueExists {
+		formData[name] = value
+		return
+	}
+
+	if list, isList := currentValue.([]interface{}); isList {
+		formData[name] = append(list, value)
+		return
+	}
+
+	formData[name] = []interface{}{currentValue, value}
+}
+
+// Checks that text data is UTF-8 (or has no charset).
+func checkCharset(params map[string]string) error {
+	if charset, hasCharset := params["charset"]; hasCharset && !strings.EqualFold(charset, "utf-8") {
+		return fmt.Errorf("unsupported charset: \"%s\"", charset)
+	}
+
+	return nil
+}
@@ -0,0 +1,38 @@
+package codecs
+
+import (
+	"encoding/json"
+	"gogogo/data"
+	"io"
+)
+
+// The JSON codec.
+type JsonCodec struct {
+	Encoder
+	Decoder
+}
+
+// Creates a new JSON codec.
+func Json() *JsonCodec {
+	return &JsonCodec{}
+}
+
+func (codec *JsonCodec) Encode(value interface{}) ([]byte, error) {
+	return json.Marshal(value)
+}
+
+func (codec *JsonCodec) Decode(reader io.Reader, params map[string]string) (decodedData data.GenericMap, err error) {
+	if err = checkCharset(params); err != nil {
+		return
+	}
+
+	var jsonData []byte
+
+	if jsonData, err = io.ReadAll(reader); err != nil {
+		return
+	}
+
+	decodedData = data.NewGenericMap()
+	err = json.Unmarshal(jsonData, &decodedData)
+	return
+}
@@ -0,0 +1,369 @@
+package codecs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"gogogo/data"
+	"io"
+	"math"
+	"time"
+)
+
+// The MessagePack codec. Decoded numbers are float64 values (like the JSON decoder ones), binary values are []byte
+// values and timestamps are RFC 3339 strings.
+type MessagePackCodec struct {
+	Encoder
+	Decoder
+}
+
+// Creates a new MessagePack codec.
+func MessagePack() *MessagePackCodec {
+	return &MessagePackCodec{}
+}
+
+func (codec *MessagePackCodec) Encode(value interface{}) (msgpackData []byte, err error) {
+	var normalizedValue interface{}
+
+	if normalizedValue, err = normalize(value); err != nil {
+		return
+	}
+
+	var buffer bytes.Buffer
+	encodeMessagePack(&buffer, normalizedValue)
+
+	return buffer.Bytes(), nil
+}
+
+func (codec *MessagePackCodec) Decode(reader io.Reader, params map[string]string) (data.GenericMap, error) {
+	var binaryReader, err = newBinaryReader(reader)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var value interface{}
+
+	if value, err = decodeMessagePack(binaryReader); err != nil {
+		return nil, err
+	}
+
+	return decodedMap(value)
+}
+
+func encodeMessagePack(buffer *bytes.Buffer, value interface{}) {
+	switch typedValue := value.(type) {
+	case nil:
+		buffer.WriteByte(0xc0)
+
+	case bool:
+		if typedValue {
+			buffer.WriteByte(0xc3)
+		} else {
+			buffer.WriteByte(0xc2)
+		}
+
+	case int64:
+		if typedValue >= 0 {
+			encodeMessagePackUint(buffer, uint64(typedValue))
+		} else if typedValue >= -32 {
+			buffer.WriteByte(byte(typedValue))
+		} else if typedValue >= math.MinInt8 {
+			buffer.Write([]byte{0xd0, byte(typedValue)})
+		} else if typedValue >= math.MinInt16 {
+			buffer.WriteByte(0xd1)
+			buffer.Write(binary.BigEndian.AppendUint16(nil, uint16(typedValue)))
+		} else if typedValue >= math.MinInt32 {
+			buffer.WriteByte(0xd2)
+			buffer.Write(binary.BigEndian.AppendUint32(nil, uint32(typedValue)))
+		} else {
+			buffer.WriteByte(0xd3)
+			buffer.Write(binary.BigEndian.AppendUint64(nil, uint64(typedValue)))
+		}
+
+	case uint64:
+		encodeMessagePackUint(buffer, typedValue)
+
+	case float64:
+		buffer.WriteByte(0xcb)
+		buffer.Write(binary.BigEndian.AppendUint64(nil, math.Float64bits(typedValue)))
+
+	case string:
+		encodeMessagePackLength(buffer, len(typedValue), 0xa0, 32, []byte{0xd9, 0xda, 0xdb})
+		buffer.WriteString(typedValue)
+
+	case []byte:
+		encodeMessagePackLength(buffer, len(typedValue), 0, 0, []byte{0xc4, 0xc5, 0xc6})
+		buffer.Write(typedValue)
+
+	case []interface{}:
+		encodeMessagePackLength(buffer, len(typedValue), 0x90, 16, []byte{0, 0xdc, 0xdd})
+
+		for _, item := range typedValue {
+			encodeMessagePack(buffer, item)
+		}
+
+	case []mapEntry:
+		encodeMessagePackLength(buffer, len(typedValue), 0x80, 16, []byte{0, 0xde, 0xdf})
+
+		for _, entry := range typedValue {
+			encodeMessagePack(buffer, entry.key)
+			encodeMessagePack(buffer, entry.value)
+		}
+
+	default:
+		panic(fmt.Sprintf("unexpected normalized type: %T", value))
+	}
+}
+
+func encodeMessagePackUint(buffer *bytes.Buffer, value uint64) {
+	switch {
+	case value <= math.MaxInt8:
+		buffer.WriteByte(byte(value))
+	case value <= math.MaxUint8:
+		buffer.Write([]byte{0xcc, byte(value)})
+	case value <= math.MaxUint16:
+		buffer.WriteByte(0xcd)
+		buffer.Write(binary.BigEndian.AppendUint16(nil, uint16(value)))
+	case value <= math.MaxUint32:
+		buffer.WriteByte(0xce)
+		buffer.Write(binary.BigEndian.AppendUint32(nil, uint32(value)))
+	default:
+		buffer.WriteByte(0xcf)
+		buffer.Write(binary.BigEndian.AppendUint64(nil, value))
+	}
+}
+
+// Writes the header of a string, binary, array or map value. Lengths under fixLimit use the fix format (fixPrefix ORed
+// with the length), longer ones use the 8 bit (if it has a prefix), 16 bit and 32 bit formats.
+func encodeMessagePackLength(buffer *bytes.Buffer, length int, fixPrefix byte, fixLimit int, prefixes []byte) {
+	switch {
+	case length < fixLimit:
+		buffer.WriteByte(fixPrefix | byte(length))
+	case (prefixes[0] != 0) && (length <= math.MaxUint8):
+		buffer.Write([]byte{prefixes[0], byte(length)})
+	case length <= math.MaxUint16:
+		buffer.WriteByte(prefixes[1])
+		buffer.Write(binary.BigEndian.AppendUint16(nil, uint16(length)))
+	default:
+		buffer.WriteByte(prefixes[2])
+		buffer.Write(binary.BigEndian.AppendUint32(nil, uint32(length)))
+	}
+}
+
+func decodeMessagePack(reader *binaryReader) (value interface{}, err error) {
+	var header []byte
+
+	if header, err = reader.next(1); err != nil {
+		return
+	}
+
+	var format = header[0]
+
+	switch {
+	case format <= 0x7f:
+		return float64(format), nil
+	case format >= 0xe0:
+		return float64(int8(format)), nil
+	case format <= 0x8f:
+		return decodeMessagePackMap(reader, uint64(format&0x0f))
+	case format <= 0x9f:
+		return decodeMessagePackArray(reader, uint64(format&0x0f))
+	case format <= 0xbf:
+		return decodeMessagePackString(reader, uint64(format&0x1f))
+	}
+
+	var length uint64
+
+	switch format {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+
+	case 0xc4, 0xc5, 0xc6:
+		if length, err = reader.uint(1 << (format - 0xc4)); err != nil {
+			return
+		}
+
+		var bytes []byte
+
+		if bytes, err = reader.next(length); err != nil {
+			return
+		}
+
+		return append(make([]byte, 0, len(bytes)), bytes...), nil
+
+	case 0xc7, 0xc8, 0xc9:
+		if length, err = reader.uint(1 << (format - 0xc7)); err != nil {
+			return
+		}
+
+		return decodeMessagePackExtension(reader, length)
+
+	case 0xca:
+		var bits uint64
+
+		if bits, err = reader.uint(4); err != nil {
+			return
+		}
+
+		return float64(math.Float32frombits(uint32(bits))), nil
+
+	case 0xcb:
+		var bits uint64
+
+		if bits, err = reader.uint(8); err != nil {
+			return
+		}
+
+		return math.Float64frombits(bits), nil
+
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		var uintValue uint64
+
+		if uintValue, err = reader.uint(1 << (format - 0xcc)); err != nil {
+			return
+		}
+
+		return float64(uintValue), nil
+
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		var size = uint64(1 << (format - 0xd0))
+		var uintValue uint64
+
+		if uintValue, err = reader.uint(size); err != nil {
+			return
+		}
+
+		// Sign extends the value from its size to 64 bits
+		var shift = 64 - size*8
+		return float64(int64(uintValue<<shift) >> shift), nil
+
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return decodeMessagePackExtension(reader, 1<<(format-0xd4))
+
+	case 0xd9, 0xda, 0xdb:
+		if length, err = reader.uint(1 << (format - 0xd9)); err != nil {
+			return
+		}
+
+		return decodeMessagePackString(reader, length)
+
+	case 0xdc, 0xdd:
+		if length, err = reader.uint(2 << (format - 0xdc)); err != nil {
+			return
+		}
+
+		return decodeMessagePackArray(reader, length)
+
+	case 0xde, 0xdf:
+		if length, err = reader.uint(2 << (format - 0xde)); err != nil {
+			return
+		}
+
+		return decodeMessagePackMap(reader, length)
+	}
+
+	return nil, fmt.Errorf("unsupported MessagePack format: 0x%02x", format)
+}
+
+func decodeMessagePackString(reader *binaryReader, length uint64) (interface{}, error) {
+	var bytes, err = reader.next(length)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return string(bytes), nil
+}
+
+func decodeMessagePackArray(reader *binaryReader, length uint64) (value interface{}, err error) {
+	if err = reader.checkCount(length); err != nil {
+		return
+	}
+
+	if err = reader.enter(); err != nil {
+		return
+	}
+
+	defer reader.leave()
+
+	var list = make([]interface{}, length)
+
+	for index := range list {
+		if list[index], err = decodeMessagePack(reader); err != nil {
+			return
+		}
+	}
+
+	return list, nil
+}
+
+func decodeMessagePackMap(reader *binaryReader, length uint64) (value interface{}, err error) {
+	if err = reader.checkCount(length); err != nil {
+		return
+	}
+
+	if err = reader.enter(); err != nil {
+		return
+	}
+
+	defer reader.leave()
+
+	var mapValue = make(map[string]interface{}, length)
+
+	for index := uint64(0); index < length; index++ {
+		var key, entryValue interface{}
+		var stringKey string
+
+		if key, err = decodeMessagePack(reader); err != nil {
+			return
+		}
+
+		if stringKey, err = mapKey(key); err != nil {
+			return
+		}
+
+		if entryValue, err = decodeMessagePack(reader); err != nil {
+			return
+		}
+
+		mapValue[stringKey] = entryValue
+	}
+
+	return mapValue, nil
+}
+
+// Decodes an extension value. Only the timestamp extension (type -1) is supported.
+func decodeMessagePackExtension(reader *binaryReader, length uint64) (value interface{}, err error) {
+	var extensionData []byte
+
+	if extensionData, err = reader.next(length + 1); err != nil {
+		return
+	}
+
+	var extensionType, payload = int8(extensionData[0]), extensionData[1:]
+
+	if extensionType != -1 {
+		return nil, fmt.Errorf("unsupported MessagePack extension type: %d", extensionType)
+	}
+
+	var timestamp time.Time
+
+	switch len(payload) {
+	case 4:
+		timestamp = time.Unix(int64(binary.BigEndian.Uint32(payload)), 0)
+	case 8:
+		var bits = binary.BigEndian.Uint64(payload)
+		timestamp = time.Unix(int64(bits&0x3ffffffff), int64(bits>>34))
+	case 12:
+		timestamp = time.Unix(int64(binary.BigEndian.Uint64(payload[4:])), int64(binary.BigEndian.Uint32(payload)))
+	default:
+		return nil, fmt.Errorf("bad MessagePack timestamp length: %d", len(payload))
+	}
+
+	return timestamp.UTC().Format(time.RFC3339Nano), nil
+}
@@ -0,0 +1,195 @@
+package codecs
+
+import (
+	"bytes"
+	"errors"
+	"gogogo/data"
+	"io"
+	"math"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+)
+
+// The decoder for multipart forms. Files are decoded as *data.File values, kept in memory up to the memory limit and
+// spooled to temporary files beyond it.
+type MultipartCodec struct {
+	Decoder
+
+	maxMemory   int64
+	maxFileSize int64
+	maxFiles    int64
+	tempDir     string
+}
+
+var (
+	ErrFileTooLarge = errors.New("file too large")
+	ErrTooManyFiles = errors.New("too many files")
+)
+
+// Creates a new multipart codec.
+func Multipart() *MultipartCodec {
+	return &MultipartCodec{
+		maxMemory:   1 << 20,
+		maxFileSize: 10 << 20,
+		maxFiles:    10,
+	}
+}
+
+// Sets the maximum size of the request files kept in memory.
+func (codec *MultipartCodec) WithMaxMemory(maxMemory int64) *MultipartCodec {
+	codec.maxMemory = maxMemory
+	return codec
+}
+
+// Sets the maximum size of each file (0 for no limit).
+func (codec *MultipartCodec) WithMaxFileSize(maxFileSize int64) *MultipartCodec {
+	codec.maxFileSize = maxFileSize
+	return codec
+}
+
+// Sets the maximum number of files in a request (0 for no limit).
+func (codec *MultipartCodec) WithMaxFiles(maxFiles int64) *MultipartCodec {
+	codec.maxFiles = maxFiles
+	return codec
+}
+
+// Sets the directory for the temporary files (the system temporary directory by default).
+func (codec *MultipartCodec) WithTempDir(tempDir string) *MultipartCodec {
+	codec.tempDir = tempDir
+	return codec
+}
+
+func (codec *MultipartCodec) Decode(reader io.Reader, params map[string]string) (decodedData data.GenericMap, err error) {
+	var boundary, hasBoundary = params["boundary"]
+
+	if !hasBoundary {
+		return nil, errors.New("missing multipart boundary")
+	}
+
+	var multipartReader = multipart.NewReader(reader, boundary)
+	var files = make([]*data.File, 0)
+	var memoryLeft = codec.maxMemory
+
+	defer func() {
+		if err != nil {
+			for _, file := range files {
+				file.Remove()
+			}
+		}
+	}()
+
+	decodedData = data.NewGenericMap()
+
+	for {
+		var part *multipart.Part
+
+		if part, err = multipartReader.NextPart(); err == io.EOF {
+			return decodedData, nil
+		} else if err != nil {
+			return
+		}
+
+		if part.FormName() == "" {
+			part.Close()
+			continue
+		}
+
+		if part.FileName() == "" {
+			var partData []byte
+
+			if partData, err = io.ReadAll(part); err != nil {
+				return
+			}
+
+			addFormValue(decodedData, part.FormName(), string(partData))
+			part.Close()
+			continue
+		}
+
+		if (codec.maxFiles > 0) && (int64(len(files)) >= codec.maxFiles) {
+			return nil, ErrTooManyFiles
+		}
+
+		var file *data.File
+
+		if file, err = codec.spool(part, &memoryLeft); err != nil {
+			return
+		}
+
+		files = append(files, file)
+		addFormValue(decodedData, part.FormName(), file)
+		part.Close()
+	}
+}
+
+// Reads a file part, keeping it in memory if it fits in the memory left or in a temporary file otherwise.
+func (codec *MultipartCodec) spool(part *multipart.Part, memoryLeft *int64) (file *data.File, err error) {
+	var maxFileSize int64 = math.MaxInt64 - 1
+
+	if codec.maxFileSize > 0 {
+		maxFileSize = codec.maxFileSize
+	}
+
+	var partReader = io.LimitReader(part, maxFileSize+1)
+	var head = make([]byte, 512)
+	var headSize int
+
+	if headSize, err = io.ReadFull(partReader, head); (err != nil) && (err != io.EOF) && (err != io.ErrUnexpectedEOF) {
+		return
+	}
+
+	head = head[:headSize]
+
+	var fileReader = io.MultiReader(bytes.NewReader(head), partReader)
+	var contents bytes.Buffer
+	var size int64
+
+	size, err = io.CopyN(&contents, fileReader, max(*memoryLeft, 0)+1)
+
+	if err == io.EOF {
+		if size > maxFileSize {
+			return nil, ErrFileTooLarge
+		}
+
+		*memoryLeft -= size
+		file = data.NewMemoryFile(part.FileName(), contents.Bytes())
+	} else if err != nil {
+		return
+	} else if file, err = codec.spoolToTempFile(part.FileName(), &contents, fileReader, maxFileSize); err != nil {
+		return
+	}
+
+	file.ContentType, _, _ = mime.ParseMediaType(http.DetectContentType(head))
+	file.DeclaredType = part.Header.Get("Content-Type")
+	return file, nil
+}
+
+// Writes the file contents read so far and the rest of the file to a temporary file.
+func (codec *MultipartCodec) spoolToTempFile(fileName string, contents io.Reader, fileReader io.Reader, maxFileSize int64) (file *data.File, err error) {
+	var tempFile *os.File
+
+	if tempFile, err = os.CreateTemp(codec.tempDir, "upload-*"); err != nil {
+		return
+	}
+
+	var size int64
+
+	if size, err = io.Copy(tempFile, io.MultiReader(contents, fileReader)); err == nil {
+		err = tempFile.Close()
+	} else {
+		tempFile.Close()
+	}
+
+	if (err == nil) && (size > maxFileSize) {
+		err = ErrFileTooLarge
+	}
+
+	if err != nil {
+		os.Remove(tempFile.Name())
+		return nil, err
+	}
+
+	return data.NewTempFile(fileName, tempFile.Name(), size), nil
+}
@@ -0,0 +1,261 @@
+package codecs
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gogogo/data"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// A map entry of a normalized value. Maps are normalized to lists of entries, sorted by key, so they are always
+// encoded the same way.
+type mapEntry struct {
+	key   interface{}
+	value interface{}
+}
+
+// Converts a value to the types encoders handle (other values are converted the way the JSON encoder would convert
+// them).
+func normalize(value interface{}) (interface{}, error) {
+	return normalizeValue(reflect.ValueOf(value))
+}
+
+var (
+	_jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	_textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	_jsonNumberType    = reflect.TypeOf(json.Number(""))
+)
+
+func normalizeValue(value reflect.Value) (interface{}, error) {
+	if !value.IsValid() {
+		return nil, nil
+	}
+
+	if value.Type() == _jsonNumberType {
+		return normalizeNumber(json.Number(value.String()))
+	}
+
+	if value.Type().Implements(_jsonMarshalerType) || value.Type().Implements(_textMarshalerType) {
+		if ((value.Kind() == reflect.Pointer) || (value.Kind() == reflect.Interface)) && value.IsNil() {
+			return nil, nil
+		}
+
+		return normalizeJson(value.Interface())
+	}
+
+	switch value.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if value.IsNil() {
+			return nil, nil
+		}
+
+		return normalizeValue(value.Elem())
+
+	case reflect.Bool:
+		return value.Bool(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return value.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return value.Uint(), nil
+	case reflect.Float32, reflect.Float64:
+		return value.Float(), nil
+	case reflect.String:
+		return value.String(), nil
+
+	case reflect.Slice, reflect.Array:
+		if (value.Kind() == reflect.Slice) && value.IsNil() {
+			return nil, nil
+		}
+
+		if value.Type().Elem().Kind() == reflect.Uint8 {
+			var bytes = make([]byte, value.Len())
+			reflect.Copy(reflect.ValueOf(bytes), value)
+			return bytes, nil
+		}
+
+		var list = make([]interface{}, value.Len())
+
+		for index := range list {
+			var err error
+
+			if list[index], err = normalizeValue(value.Index(index)); err != nil {
+				return nil, err
+			}
+		}
+
+		return list, nil
+
+	case reflect.Map:
+		if value.IsNil() {
+			return nil, nil
+		}
+
+		var entries = make([]mapEntry, 0, value.Len())
+		var mapIterator = value.MapRange()
+
+		for mapIterator.Next() {
+			var key, err = normalizeValue(mapIterator.Key())
+
+			if err != nil {
+				return nil, err
+			}
+
+			var entryValue interface{}
+
+			if entryValue, err = normalizeValue(mapIterator.Value()); err != nil {
+				return nil, err
+			}
+
+			entries = append(entries, mapEntry{key, entryValue})
+		}
+
+		sort.Slice(entries, func(i int, j int) bool {
+			return fmt.Sprint(entries[i].key) < fmt.Sprint(entries[j].key)
+		})
+
+		return entries, nil
+
+	case reflect.Struct:
+		return normalizeJson(value.Interface())
+	}
+
+	return nil, fmt.Errorf("unsupported type: %s", value.Type())
+}
+
+// Normalizes a value through its JSON representation.
+func normalizeJson(value interface{}) (interface{}, error) {
+	var jsonData, err = json.Marshal(value)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var decoder = json.NewDecoder(bytes.NewReader(jsonData))
+	var decodedValue interface{}
+
+	decoder.UseNumber()
+
+	if err = decoder.Decode(&decodedValue); err != nil {
+		return nil, err
+	}
+
+	return normalize(decodedValue)
+}
+
+// Converts JSON numbers to integers when they have no fractional part (and fit in 64 bits).
+func normalizeNumber(number json.Number) (interface{}, error) {
+	if intValue, err := strconv.ParseInt(string(number), 10, 64); err == nil {
+		return intValue, nil
+	}
+
+	if uintValue, err := strconv.ParseUint(string(number), 10, 64); err == nil {
+		return uintValue, nil
+	}
+
+	var floatValue, err = strconv.ParseFloat(string(number), 64)
+
+	if err != nil || math.IsInf(floatValue, 0) {
+		return nil, fmt.Errorf("invalid number: %s", number)
+	}
+
+	return floatValue, nil
+}
+
+// The maximum nesting depth of the data the binary decoders accept (the same the JSON decoder has).
+const (
+	maxDecodeDepth = 10000
+)
+
+// A cursor over the data read by the binary decoders.
+type binaryReader struct {
+	data   []byte
+	offset int
+	depth  int
+}
+
+func newBinaryReader(reader io.Reader) (*binaryReader, error) {
+	var binaryData, err = io.ReadAll(reader)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &binaryReader{data: binaryData}, nil
+}
+
+// Returns the next bytes, failing if there are not enough of them.
+func (reader *binaryReader) next(count uint64) ([]byte, error) {
+	if count > uint64(len(reader.data)-reader.offset) {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	var bytes = reader.data[reader.offset : reader.offset+int(count)]
+	reader.offset += int(count)
+
+	return bytes, nil
+}
+
+// Returns the next bytes as an unsigned big endian integer.
+func (reader *binaryReader) uint(size uint64) (value uint64, err error) {
+	var bytes []byte
+
+	if bytes, err = reader.next(size); err != nil {
+		return
+	}
+
+	for _, currentByte := range bytes {
+		value = value<<8 | uint64(currentByte)
+	}
+
+	return
+}
+
+// Checks that a list or map with the specified number of items fits in the remaining data (each item takes at least
+// one byte), so bogus lengths don't cause huge allocations.
+func (reader *binaryReader) checkCount(count uint64) error {
+	if count > uint64(len(reader.data)-reader.offset) {
+		return io.ErrUnexpectedEOF
+	}
+
+	return nil
+}
+
+func (reader *binaryReader) enter() error {
+	if reader.depth++; reader.depth > maxDecodeDepth {
+		return errors.New("data is too deeply nested")
+	}
+
+	return nil
+}
+
+func (reader *binaryReader) leave() {
+	reader.depth--
+}
+
+// Converts a decoded map key to a string (as maps are decoded to generic maps, with string keys).
+func mapKey(key interface{}) (string, error) {
+	switch typedKey := key.(type) {
+	case string:
+		return typedKey, nil
+	case nil, bool, float64:
+		return fmt.Sprint(typedKey), nil
+	}
+
+	return "", fmt.Errorf("unsupported map key type: %T", key)
+}
+
+// Returns decoded data as a generic map, failing if it is not a map.
+func decodedMap(value interface{}) (data.GenericMap, error) {
+	if mapValue, isMap := value.(map[string]interface{}); isMap {
+		return data.GenericMap(mapValue), nil
+	}
+
+	return nil, fmt.Errorf("data must be a map (not %T)", value)
+}
@@ -0,0 +1,100 @@
+package codecs
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// The XML codec. Values are encoded as a "response" element, with an element for each map entry and an "item" element
+// for each list item.
+type XmlCodec struct {
+	Encoder
+}
+
+// Creates a new XML codec.
+func Xml() *XmlCodec {
+	return &XmlCodec{}
+}
+
+func (codec *XmlCodec) Encode(value interface{}) (xmlData []byte, err error) {
+	var normalizedValue interface{}
+
+	if normalizedValue, err = normalize(value); err != nil {
+		return
+	}
+
+	var buffer bytes.Buffer
+	var encoder = xml.NewEncoder(&buffer)
+
+	buffer.WriteString(xml.Header)
+
+	if err = encodeXml(encoder, xml.StartElement{Name: xml.Name{Local: "response"}}, normalizedValue); err != nil {
+		return
+	}
+
+	if err = encoder.Flush(); err != nil {
+		return
+	}
+
+	return buffer.Bytes(), nil
+}
+
+var (
+	_xmlNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+)
+
+func encodeXml(encoder *xml.Encoder, element xml.StartElement, value interface{}) (err error) {
+	if err = encoder.EncodeToken(element); err != nil {
+		return
+	}
+
+	switch typedValue := value.(type) {
+	case nil:
+	case bool:
+		err = encoder.EncodeToken(xml.CharData(strconv.FormatBool(typedValue)))
+	case int64:
+		err = encoder.EncodeToken(xml.CharData(strconv.FormatInt(typedValue, 10)))
+	case uint64:
+		err = encoder.EncodeToken(xml.CharData(strconv.FormatUint(typedValue, 10)))
+	case float64:
+		err = encoder.EncodeToken(xml.CharData(strconv.FormatFloat(typedValue, 'g', -1, 64)))
+	case string:
+		err = encoder.EncodeToken(xml.CharData(typedValue))
+	case []byte:
+		err = encoder.EncodeToken(xml.CharData(base64.StdEncoding.EncodeToString(typedValue)))
+
+	case []interface{}:
+		for _, item := range typedValue {
+			if err = encodeXml(encoder, xml.StartElement{Name: xml.Name{Local: "item"}}, item); err != nil {
+				return
+			}
+		}
+
+	case []mapEntry:
+		for _, entry := range typedValue {
+			var key = fmt.Sprint(entry.key)
+			var entryElement = xml.StartElement{Name: xml.Name{Local: key}}
+
+			if !_xmlNameRegex.MatchString(key) {
+				entryElement = xml.StartElement{
+					Name: xml.Name{Local: "entry"},
+					Attr: []xml.Attr{{Name: xml.Name{Local: "key"}, Value: key}},
+				}
+			}
+
+			if err = encodeXml(encoder, entryElement, entry.value); err != nil {
+				return
+			}
+		}
+	}
+
+	if err != nil {
+		return
+	}
+
+	return encoder.EncodeToken(element.End())
+}
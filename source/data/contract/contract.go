@@ -46,10 +46,15 @@ type FieldValidator interface {
 	Validate(value interface{}) *ValidationError
 }
 
+// Converts a field value to the field type before it is validated, telling whether it did. Strings are only converted
+// for values decoded from text (see Contract.ConvertText).
+type FieldConverter func(value interface{}, isText bool) (interface{}, bool)
+
 type Field struct {
 	name       string
 	isRequired bool
 	validators []FieldValidator
+	converter  FieldConverter
 }
 
 func GenericField(name string) Field {
@@ -88,6 +93,14 @@ func (field *Field) IsRequired() bool {
 	return field.isRequired
 }
 
+func (field *Field) convert(value interface{}, isText bool) (interface{}, bool) {
+	if field.converter == nil {
+		return value, false
+	}
+
+	return field.converter(value, isText)
+}
+
 func (field *Field) addValidator(validator FieldValidator) {
 	field.validators = append(field.validators, validator)
 }
@@ -123,7 +136,14 @@ func (contract *Contract) Validate(data data.GenericMap) (errors map[string]*Val
 			continue
 		}
 
-		var fieldError = field.Validate(data.Get(fieldName, nil))
+		var value = data.Get(fieldName, nil)
+
+		if convertedValue, isConverted := field.convert(value, false); isConverted {
+			value = convertedValue
+			data.Set(fieldName, value)
+		}
+
+		var fieldError = field.Validate(value)
 
 		if fieldError != nil {
 			errors[fieldName] = fieldError
@@ -141,3 +161,33 @@ func (contract *Contract) Validate(data data.GenericMap) (errors map[string]*Val
 
 	return
 }
+
+// Converts the values of the specified fields, decoded from text (like form fields, which are always strings), to the
+// field types, so they validate like the values decoded from typed wire formats (like JSON).
+func (contract *Contract) ConvertText(data data.GenericMap, fieldNames []string) {
+	for _, fieldName := range fieldNames {
+		if field, nameExists := contract.fields[fieldName]; nameExists && data.Has(fieldName) {
+			if value, isConverted := field.convert(data.Get(fieldName, nil), true); isConverted {
+				data.Set(fieldName, value)
+			}
+		}
+	}
+}
+
+// Creates a new contract with the fields of all the specified contracts (nil contracts are ignored). When more than one
+// contract has a field with the same name, the field from the last one is used.
+func Merge(contracts ...*Contract) (contract *Contract) {
+	contract = New()
+
+	for _, otherContract := range contracts {
+		if otherContract == nil {
+			continue
+		}
+
+		for fieldName, field := range otherContract.fields {
+			contract.fields[fieldName] = field
+		}
+	}
+
+	return
+}
@@ -0,0 +1,167 @@
+package contract
+
+import (
+	"fmt"
+	"gogogo/data"
+	"path"
+	"strings"
+)
+
+// A field for uploaded files (data.File values). A single file is accepted by default (use Count to accept more).
+type FileField struct {
+	Field
+
+	count *fileCountValidator
+}
+
+func File(name string) *FileField {
+	var field = &FileField{
+		Field: GenericField(name),
+		count: &fileCountValidator{min: 1, max: 1},
+	}
+
+	field.addValidator(&fileTypeValidator{})
+	field.addValidator(field.count)
+
+	return field
+}
+
+type fileTypeValidator struct {
+	FieldValidator
+}
+
+func (validator *fileTypeValidator) Validate(value interface{}) (err *ValidationError) {
+	if fieldFiles(value) == nil {
+		return &ValidationError{
+			ErrorCode:    InvalidValueType,
+			ErrorMessage: "value must be a file",
+		}
+	}
+
+	return
+}
+
+type fileCountValidator struct {
+	FieldValidator
+
+	min int64
+	max int64
+}
+
+func (validator *fileCountValidator) Validate(value interface{}) (err *ValidationError) {
+	var fileCount = int64(len(fieldFiles(value)))
+
+	if validator.min > 0 && fileCount < validator.min {
+		return &ValidationError{
+			ErrorCode:    InvalidLength,
+			ErrorMessage: fmt.Sprintf("file count must be greater or equals to %d", validator.min),
+		}
+	}
+
+	if validator.max > 0 && fileCount > validator.max {
+		return &ValidationError{
+			ErrorCode:    InvalidLength,
+			ErrorMessage: fmt.Sprintf("file count must be less or equals to %d", validator.max),
+		}
+	}
+
+	return
+}
+
+// Sets how many files the field accepts (a max of 0 accepts any number of files).
+func (field *FileField) Count(min int64, max int64) *FileField {
+	field.count.min = min
+	field.count.max = max
+
+	return field
+}
+
+type fileSizeValidator struct {
+	FieldValidator
+
+	max int64
+}
+
+func (validator *fileSizeValidator) Validate(value interface{}) (err *ValidationError) {
+	for _, file := range fieldFiles(value) {
+		if file.Size > validator.max {
+			return &ValidationError{
+				ErrorCode:    ValueOutOfRange,
+				ErrorMessage: fmt.Sprintf("file size must be less or equals to %d bytes", validator.max),
+			}
+		}
+	}
+
+	return
+}
+
+// Sets the maximum size (in bytes) of each file.
+func (field *FileField) MaxSize(max int64) *FileField {
+	field.addValidator(&fileSizeValidator{
+		max: max,
+	})
+
+	return field
+}
+
+type fileContentTypeValidator struct {
+	FieldValidator
+
+	contentTypes []string
+}
+
+func (validator *fileContentTypeValidator) Validate(value interface{}) (err *ValidationError) {
+	for _, file := range fieldFiles(value) {
+		if !validator.accepts(file.ContentType) {
+			return &ValidationError{
+				ErrorCode:    InvalidValue,
+				ErrorMessage: fmt.Sprintf("file type must be one of %s", strings.Join(validator.contentTypes, ", ")),
+			}
+		}
+	}
+
+	return
+}
+
+func (validator *fileContentTypeValidator) accepts(contentType string) bool {
+	for _, acceptedType := range validator.contentTypes {
+		if isMatch, _ := path.Match(acceptedType, contentType); isMatch {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Sets the content types the files may have (like "image/png" or "image/*"). File content types are sniffed from their
+// contents, the types declared by clients are not checked.
+func (field *FileField) Types(contentTypes ...string) *FileField {
+	field.addValidator(&fileContentTypeValidator{
+		contentTypes: contentTypes,
+	})
+
+	return field
+}
+
+// Returns the files in a field value (a file or a list of files), or nil if the value has something else.
+func fieldFiles(value interface{}) []*data.File {
+	switch typedValue := value.(type) {
+	case *data.File:
+		return []*data.File{typedValue}
+
+	case []interface{}:
+		var files = make([]*data.File, 0, len(typedValue))
+
+		for _, item := range typedValue {
+			if file, isFile := item.(*data.File); isFile {
+				files = append(files, file)
+			} else {
+				return nil
+			}
+		}
+
+		return files
+	}
+
+	return nil
+}
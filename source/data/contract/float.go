@@ -3,6 +3,7 @@ package contract
 import (
 	"fmt"
 	"reflect"
+	"strconv"
 )
 
 type FloatField struct {
@@ -14,11 +15,23 @@ func Float(name string) *FloatField {
 		Field: GenericField(name),
 	}
 
+	field.converter = toFloat
 	field.addValidator(&floatTypeValidator{})
 
 	return field
 }
 
+// Converts numeric text values to floats.
+func toFloat(value interface{}, isText bool) (interface{}, bool) {
+	if stringValue, isString := value.(string); isString && isText {
+		if floatValue, err := strconv.ParseFloat(stringValue, 64); err == nil {
+			return floatValue, true
+		}
+	}
+
+	return value, false
+}
+
 type floatTypeValidator struct {
 	FieldValidator
 }
@@ -2,7 +2,9 @@ package contract
 
 import (
 	"fmt"
+	"math"
 	"reflect"
+	"strconv"
 )
 
 type IntegerField struct {
@@ -14,11 +16,29 @@ func Integer(name string) *IntegerField {
 		Field: GenericField(name),
 	}
 
+	field.converter = toInteger
 	field.addValidator(&integerTypeValidator{})
 
 	return field
 }
 
+// Converts integer text values and integral floats (as numbers are decoded) to integers.
+func toInteger(value interface{}, isText bool) (interface{}, bool) {
+	switch typedValue := value.(type) {
+	case string:
+		if intValue, err := strconv.ParseInt(typedValue, 10, 64); isText && (err == nil) {
+			return intValue, true
+		}
+
+	case float64:
+		if (typedValue == math.Trunc(typedValue)) && (math.Abs(typedValue) < (1 << 63)) {
+			return int64(typedValue), true
+		}
+	}
+
+	return value, false
+}
+
 type integerTypeValidator struct {
 	FieldValidator
 }
@@ -0,0 +1,168 @@
+package contract
+
+import (
+	"gogogo/data"
+	"sort"
+)
+
+// Implemented by validators that can describe their constraints as JSON Schema keywords.
+type schemaDescriber interface {
+	describeSchema(schema data.GenericMap)
+}
+
+// Returns the JSON Schema describing the values accepted by the field.
+func (field *Field) Schema() data.GenericMap {
+	var schema = data.NewGenericMap()
+
+	for _, validator := range field.validators {
+		if describer, isDescriber := validator.(schemaDescriber); isDescriber {
+			describer.describeSchema(schema)
+		}
+	}
+
+	return schema
+}
+
+// Returns the contract fields, sorted by name.
+func (contract *Contract) Fields() []*Field {
+	var fields = make([]*Field, 0, len(contract.fields))
+
+	for _, field := range contract.fields {
+		fields = append(fields, field)
+	}
+
+	sort.Slice(fields, func(i, j int) bool {
+		return fields[i].name < fields[j].name
+	})
+
+	return fields
+}
+
+// Returns the JSON Schema describing the data accepted by the contract, leaving out the excluded fields.
+func (contract *Contract) Schema(excludedFields ...string) data.GenericMap {
+	var properties = data.NewGenericMap()
+	var requiredFields = make([]string, 0)
+
+	for _, field := range contract.Fields() {
+		if isExcluded(field.name, excludedFields) {
+			continue
+		}
+
+		properties.Set(field.name, field.Schema())
+
+		if field.isRequired {
+			requiredFields = append(requiredFields, field.name)
+		}
+	}
+
+	var schema = data.GenericMap{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+
+	if len(requiredFields) > 0 {
+		schema.Set("required", requiredFields)
+	}
+
+	return schema
+}
+
+// Returns the JSON Schema describing the validation errors returned by Validate.
+func ValidationErrorsSchema() data.GenericMap {
+	var errorCodes = make([]ValidationErrorCode, 0)
+
+	for code := InvalidLength; code <= ValueOutOfRange; code++ {
+		errorCodes = append(errorCodes, code)
+	}
+
+	return data.GenericMap{
+		"type": "object",
+		"additionalProperties": data.GenericMap{
+			"type": "object",
+			"properties": data.GenericMap{
+				"errorCode":    data.GenericMap{"type": "integer", "enum": errorCodes},
+				"errorMessage": data.GenericMap{"type": "string"},
+			},
+			"required": []string{"errorCode", "errorMessage"},
+		},
+	}
+}
+
+func isExcluded(fieldName string, excludedFields []string) bool {
+	for _, excludedField := range excludedFields {
+		if excludedField == fieldName {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (validator *stringTypeValidator) describeSchema(schema data.GenericMap) {
+	schema.Set("type", "string")
+}
+
+func (validator *stringLengthValidator) describeSchema(schema data.GenericMap) {
+	if validator.min > 0 {
+		schema.Set("minLength", validator.min)
+	}
+
+	if validator.max > 0 {
+		schema.Set("maxLength", validator.max)
+	}
+}
+
+func (validator *stringRegexValidator) describeSchema(schema data.GenericMap) {
+	schema.Set("pattern", validator.regex.String())
+}
+
+func (validator *stringAcceptValidator) describeSchema(schema data.GenericMap) {
+	schema.Set("enum", validator.acceptedValues)
+}
+
+func (validator *integerTypeValidator) describeSchema(schema data.GenericMap) {
+	schema.Set("type", "integer")
+}
+
+func (validator *integerRangeValidator) describeSchema(schema data.GenericMap) {
+	schema.Set("minimum", validator.min)
+	schema.Set("maximum", validator.max)
+}
+
+func (validator *floatTypeValidator) describeSchema(schema data.GenericMap) {
+	schema.Set("type", "number")
+}
+
+func (validator *floatRangeValidator) describeSchema(schema data.GenericMap) {
+	schema.Set("minimum", validator.min)
+	schema.Set("maximum", validator.max)
+}
+
+func (validator *fileTypeValidator) describeSchema(schema data.GenericMap) {
+	schema.Set("type", "string")
+	schema.Set("format", "binary")
+}
+
+func (validator *fileCountValidator) describeSchema(schema data.GenericMap) {
+	if validator.max == 1 {
+		return
+	}
+
+	var items = data.NewGenericMap().MergeWith(schema)
+
+	for key := range items {
+		schema.Unset(key)
+	}
+
+	schema.Set("type", "array")
+	schema.Set("items", items)
+
+	if validator.min > 0 {
+		schema.Set("minItems", validator.min)
+	}
+
+	if validator.max > 0 {
+		schema.Set("maxItems", validator.max)
+	}
+}
@@ -0,0 +1,74 @@
+package data
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+// An uploaded file. Small files are kept in memory and larger ones in temporary files, which must be removed with
+// Remove once the file is no longer needed.
+type File struct {
+	Name         string // The file name sent by the client.
+	ContentType  string // The content type sniffed from the file contents.
+	DeclaredType string // The content type sent by the client (which should not be trusted).
+	Size         int64
+
+	contents []byte
+	path     string
+}
+
+// Creates a new file kept in memory.
+func NewMemoryFile(name string, contents []byte) *File {
+	return &File{
+		Name:     name,
+		Size:     int64(len(contents)),
+		contents: contents,
+	}
+}
+
+// Creates a new file kept in a temporary file (which is deleted when the file is removed).
+func NewTempFile(name string, path string, size int64) *File {
+	return &File{
+		Name: name,
+		Size: size,
+		path: path,
+	}
+}
+
+// Opens the file contents for reading.
+func (file *File) Open() (io.ReadSeekCloser, error) {
+	if file.path != "" {
+		return os.Open(file.path)
+	}
+
+	return nopCloser{bytes.NewReader(file.contents)}, nil
+}
+
+// Checks whether the file contents are kept in a temporary file.
+func (file *File) IsTemporary() bool {
+	return file.path != ""
+}
+
+// Removes the file temporary file, if any.
+func (file *File) Remove() error {
+	if file.path == "" {
+		return nil
+	}
+
+	var err = os.Remove(file.path)
+
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	return err
+}
+
+type nopCloser struct {
+	io.ReadSeeker
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
@@ -222,6 +222,15 @@ func (m GenericMap) GetBool(key string, defaultValue bool) bool {
 	return defaultValue
 }
 
+// Returns a value from the map as a list of strings. If the key does not exists the defaultValue is returned.
+func (m GenericMap) GetStrings(key string, defaultValue []string) []string {
+	if value, keyExists := m[key]; keyExists {
+		return ToStrings(value, defaultValue)
+	}
+
+	return defaultValue
+}
+
 func flattenValues(path string, separator string, values GenericMap) GenericMap {
 	var flatValues = NewGenericMap()
 
@@ -133,3 +133,33 @@ func ToBool(value interface{}, defaultValue bool) bool {
 
 	return defaultValue
 }
+
+// Converts a value to a list of strings. Strings are split on commas (with the spaces around the items removed).
+func ToStrings(value interface{}, defaultValue []string) []string {
+	switch typedValue := value.(type) {
+	case string:
+		var values = make([]string, 0)
+
+		for _, item := range strings.Split(typedValue, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				values = append(values, item)
+			}
+		}
+
+		return values
+
+	case []string:
+		return typedValue
+
+	case []interface{}:
+		var values = make([]string, 0, len(typedValue))
+
+		for _, item := range typedValue {
+			values = append(values, ToString(item, ""))
+		}
+
+		return values
+	}
+
+	return defaultValue
+}
@@ -2,4 +2,7 @@ module gogogo
 
 go 1.21
 
-require github.com/google/uuid v1.4.0
+require (
+	github.com/google/uuid v1.4.0
+	golang.org/x/crypto v0.31.0
+)
@@ -17,8 +17,8 @@ func main() {
 
 	service.AddPublicPull("notes", NotesPull, nil)
 	service.AddPublicPush("notes", NotePush, NotePushContract)
-	service.AddPublicPull("notes/:id", NotePull, NotePullContract)
-	service.AddPublicUpdate("notes/:id", NoteUpdate, NoteUpdateContract)
+	service.AddPublicPull("notes/:id<uuid>", NotePull, nil)
+	service.AddPublicUpdate("notes/:id<uuid>", NoteUpdate, NoteUpdateContract)
 
 	service.Run()
 }
@@ -3,7 +3,6 @@ package main
 import (
 	"gogogo/data/contract"
 	"gogogo/requests"
-	"regexp"
 
 	"github.com/google/uuid"
 )
@@ -26,10 +25,6 @@ func NotesPull(request *requests.Request, response *requests.Response) (err erro
 	return
 }
 
-var IdRegex = regexp.MustCompile("^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$")
-
-var NotePullContract = contract.New(contract.String("id").Regex(IdRegex).Required())
-
 func NotePull(request *requests.Request, response *requests.Response) (err error) {
 	var foundNote, noteExists = notes[request.Data.GetString("id", "")]
 
@@ -62,7 +57,7 @@ func NotePush(request *requests.Request, response *requests.Response) (err error
 }
 
 var NoteUpdateContract = contract.New(
-	contract.String("id").Regex(IdRegex).Required(),
+	contract.String("id").Required(),
 	contract.String("name").Length(3, 32).Required(),
 	contract.String("contents").Length(1, 100).Optional(),
 )
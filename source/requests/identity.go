@@ -0,0 +1,41 @@
+package requests
+
+import "gogogo/data"
+
+// Describes the authenticated client of a request.
+type Identity struct {
+	Subject string
+	Roles   []string
+	Scopes  []string
+	Claims  data.GenericMap
+}
+
+// Creates a new identity for the specified subject.
+func NewIdentity(subject string, roles ...string) *Identity {
+	return &Identity{
+		Subject: subject,
+		Roles:   append(make([]string, 0, len(roles)), roles...),
+		Scopes:  make([]string, 0),
+		Claims:  data.NewGenericMap(),
+	}
+}
+
+// Checks whether the identity has the specified role.
+func (identity *Identity) HasRole(role string) bool {
+	return contains(identity.Roles, role)
+}
+
+// Checks whether the identity was granted the specified scope.
+func (identity *Identity) HasScope(scope string) bool {
+	return contains(identity.Scopes, scope)
+}
+
+func contains(values []string, value string) bool {
+	for _, currentValue := range values {
+		if currentValue == value {
+			return true
+		}
+	}
+
+	return false
+}
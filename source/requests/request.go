@@ -1,6 +1,7 @@
 package requests
 
 import (
+	"context"
 	"crypto/sha512"
 	"encoding/hex"
 	"gogogo/data"
@@ -15,6 +16,7 @@ const (
 	Push
 	Update
 	Delete
+	Replace // Replaces a resource as a whole, unlike Update, which changes only the specified fields.
 )
 
 func (_type Type) String() string {
@@ -29,6 +31,8 @@ func (_type Type) String() string {
 		return "Update"
 	case Delete:
 		return "Delete"
+	case Replace:
+		return "Replace"
 	}
 
 	return "?"
@@ -40,6 +44,10 @@ type Request struct {
 	Path     string
 	Data     data.GenericMap
 	Metadata data.GenericMap
+	Route    *Route
+	Identity *Identity
+
+	context context.Context
 }
 
 // Creates a new, empty request (with a random ID).
@@ -57,6 +65,61 @@ func NewRequest() *Request {
 		Id:       hex.EncodeToString(randomHash[randomStart : randomStart+16]),
 		Data:     data.NewGenericMap(),
 		Metadata: data.NewGenericMap(),
+		context:  context.Background(),
+	}
+}
+
+// Creates a copy of the request, with its own data, metadata and identity (the values themselves are shared).
+func (request *Request) Clone() *Request {
+	var clone = *request
+
+	clone.Data = data.NewGenericMap().MergeWith(request.Data)
+	clone.Metadata = data.NewGenericMap().MergeWith(request.Metadata)
+
+	if request.Identity != nil {
+		var identity = *request.Identity
+		clone.Identity = &identity
+	}
+
+	return &clone
+}
+
+// Returns the request context, which is canceled when the client goes away or the request times out.
+func (request *Request) Context() context.Context {
+	return request.context
+}
+
+// Sets the request context.
+func (request *Request) SetContext(requestContext context.Context) {
+	request.context = requestContext
+}
+
+// Returns the files uploaded in a request data field (none if the field has no files).
+func (request *Request) Files(name string) []*data.File {
+	var files = make([]*data.File, 0)
+
+	switch value := request.Data.Get(name, nil).(type) {
+	case *data.File:
+		files = append(files, value)
+
+	case []interface{}:
+		for _, item := range value {
+			if file, isFile := item.(*data.File); isFile {
+				files = append(files, file)
+			}
+		}
+	}
+
+	return files
+}
+
+// Removes the temporary files of the files uploaded in the request. Listeners remove them once the request is handled,
+// so handlers must copy the files they want to keep.
+func (request *Request) RemoveFiles() {
+	for name := range request.Data {
+		for _, file := range request.Files(name) {
+			file.Remove()
+		}
 	}
 }
 
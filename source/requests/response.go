@@ -14,6 +14,8 @@ const (
 	ResourceCreated
 	ResourceNotFound
 	ResourceAlreadyExists
+	Timeout
+	TooManyRequests
 )
 
 func (status Status) String() string {
@@ -36,6 +38,10 @@ func (status Status) String() string {
 		return "ResourceNotFound"
 	case ResourceAlreadyExists:
 		return "ResourceAlreadyExists"
+	case Timeout:
+		return "Timeout"
+	case TooManyRequests:
+		return "TooManyRequests"
 	}
 
 	return "?"
@@ -46,6 +52,7 @@ type Response struct {
 	Status    Status
 	Data      data.GenericMap
 	Metadata  data.GenericMap
+	Stream    StreamFunc // Makes the response a streaming one (for OK responses, the data is not sent).
 }
 
 // Creates a new, empty response (using the specified request ID).
@@ -0,0 +1,17 @@
+package requests
+
+import (
+	"gogogo/data"
+	"gogogo/data/contract"
+)
+
+// Describes the route a request was matched to.
+type Route struct {
+	Type     Type
+	Path     string
+	IsPublic bool
+	Contract *contract.Contract
+	Roles    []string
+	Scopes   []string
+	Settings data.GenericMap
+}
@@ -0,0 +1,31 @@
+package requests
+
+import (
+	"errors"
+	"time"
+)
+
+// An event sent on a streaming response.
+type Event struct {
+	Id    string        // The event ID, which clients send back as the last event ID when they reconnect.
+	Name  string        // The event type (clients treat events without a type as "message" events).
+	Data  interface{}   // The event data (strings are sent as they are, other values are encoded as JSON).
+	Retry time.Duration // The time clients should wait before reconnecting (if not zero).
+}
+
+// Sends the events of a streaming response. Listeners provide event writers to the stream functions of streaming
+// responses.
+type EventWriter interface {
+	// Sends an event. It fails with ErrStreamClosed once the client goes away.
+	Send(event *Event) error
+	// Returns the ID of the last event the client got before reconnecting (or an empty string).
+	LastEventId() string
+}
+
+// The function signature for response streams. Streams send events until they return or the request context is
+// canceled (which happens when the client goes away).
+type StreamFunc func(events EventWriter) error
+
+var (
+	ErrStreamClosed = errors.New("stream closed")
+)
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"errors"
+	"gogogo/log"
+	"gogogo/requests"
+	"sync"
+	"sync/atomic"
+)
+
+// Validates request credentials and returns the identity they belong to. Authenticators return a nil identity (and no
+// error) when the request carries no credentials they understand, and an error when the credentials are invalid. Errors
+// wrapping ErrNotAuthorized reject the request with the NotAuthorized status instead of AuthenticationRequired.
+type Authenticator interface {
+	Authenticate(request *requests.Request) (*requests.Identity, error)
+}
+
+// Implemented by authenticators that can tell clients how to authenticate (HTTP listeners send the challenges as
+// WWW-Authenticate headers).
+type Challenger interface {
+	Challenge() string
+}
+
+var (
+	ErrNotAuthorized = errors.New("not authorized")
+)
+
+// Adds authenticators, which are tried in the order they were added until one of them returns an identity or an error.
+func AddAuthenticator(authenticators ...Authenticator) {
+	_authenticatorsMutex.Lock()
+	defer _authenticatorsMutex.Unlock()
+
+	var newAuthenticators = append(append([]Authenticator{}, currentAuthenticators()...), authenticators...)
+	_authenticators.Store(&newAuthenticators)
+}
+
+// Replaces the added authenticators.
+func SetAuthenticators(authenticators ...Authenticator) {
+	_authenticatorsMutex.Lock()
+	defer _authenticatorsMutex.Unlock()
+
+	var newAuthenticators = append(make([]Authenticator, 0, len(authenticators)), authenticators...)
+	_authenticators.Store(&newAuthenticators)
+}
+
+// The authenticators are replaced as a whole (like the route table), so requests never see a partial change.
+var (
+	_authenticators      atomic.Pointer[[]Authenticator]
+	_authenticatorsMutex sync.Mutex
+)
+
+func init() {
+	var authenticators = make([]Authenticator, 0)
+	_authenticators.Store(&authenticators)
+}
+
+func currentAuthenticators() []Authenticator {
+	return *_authenticators.Load()
+}
+
+func authenticate(request *requests.Request) (identity *requests.Identity, err error) {
+	var authenticators = currentAuthenticators()
+
+	if len(authenticators) == 0 {
+		log.Verbose(_logTag, "(%s) no authenticators added", request.Id)
+		return
+	}
+
+	for _, authenticator := range authenticators {
+		if identity, err = authenticator.Authenticate(request); (identity != nil) || (err != nil) {
+			return
+		}
+	}
+
+	return
+}
+
+// Rejects a request with the AuthenticationRequired status, adding the authenticators challenges to the response
+// metadata.
+func requireAuthentication(response *requests.Response) {
+	var challenges = make([]string, 0)
+
+	for _, authenticator := range currentAuthenticators() {
+		if challenger, isChallenger := authenticator.(Challenger); isChallenger {
+			challenges = append(challenges, challenger.Challenge())
+		}
+	}
+
+	response.Status = requests.AuthenticationRequired
+	response.Metadata.Set("challenges", challenges)
+}
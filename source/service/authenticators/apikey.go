@@ -0,0 +1,240 @@
+package authenticators
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gogogo/config"
+	"gogogo/data"
+	"gogogo/log"
+	"gogogo/requests"
+	"gogogo/service"
+	"strings"
+	"time"
+)
+
+// An API key entry. Only the key SHA-256 hash is stored.
+type ApiKey struct {
+	Name      string
+	Hash      []byte
+	Roles     []string
+	Scopes    []string
+	ExpiresAt time.Time
+}
+
+// Provides the API key entries to check keys against.
+type ApiKeyStore interface {
+	Keys() []*ApiKey
+}
+
+// Hashes an API key, returning the hex encoded hash to be used in key stores.
+func HashApiKey(key string) string {
+	var keyHash = sha256.Sum256([]byte(key))
+	return hex.EncodeToString(keyHash[:])
+}
+
+// An authenticator for static API keys, read from a header (auth.apiKey.header, "X-Api-Key" by default) or from a
+// query parameter (auth.apiKey.queryParameter, disabled by default).
+type ApiKeyAuthenticator struct {
+	service.Authenticator
+
+	store          ApiKeyStore
+	header         string
+	queryParameter string
+}
+
+// Creates a new API key authenticator, checking the keys of the specified store.
+func ApiKeys(store ApiKeyStore) *ApiKeyAuthenticator {
+	var authenticator = &ApiKeyAuthenticator{
+		store:          store,
+		header:         config.GetString("auth.apiKey.header", "X-Api-Key"),
+		queryParameter: config.GetString("auth.apiKey.queryParameter", ""),
+	}
+
+	log.Verbose(apiKeyLogTag, "header = %s", authenticator.header)
+	log.Verbose(apiKeyLogTag, "query parameter = %s", authenticator.queryParameter)
+
+	return authenticator
+}
+
+func (authenticator *ApiKeyAuthenticator) Authenticate(request *requests.Request) (*requests.Identity, error) {
+	var key = authenticator.key(request)
+
+	if key == "" {
+		return nil, nil
+	}
+
+	var keyHash = sha256.Sum256([]byte(key))
+	var foundKey *ApiKey
+
+	// All the entries are compared (in constant time) so the time taken does not depend on which entry matched.
+	for _, apiKey := range authenticator.store.Keys() {
+		if (subtle.ConstantTimeCompare(keyHash[:], apiKey.Hash) == 1) && (foundKey == nil) {
+			foundKey = apiKey
+		}
+	}
+
+	if foundKey == nil {
+		return nil, errors.New("invalid API key")
+	}
+
+	if !foundKey.ExpiresAt.IsZero() && time.Now().After(foundKey.ExpiresAt) {
+		return nil, fmt.Errorf("API key %s expired", foundKey.Name)
+	}
+
+	var identity = requests.NewIdentity(foundKey.Name, foundKey.Roles...)
+	identity.Scopes = append(identity.Scopes, foundKey.Scopes...)
+	identity.Claims.Set("apiKey", foundKey.Name)
+
+	return identity, nil
+}
+
+// Returns the API key sent with the request. Keys sent as query parameters are removed from the request data.
+func (authenticator *ApiKeyAuthenticator) key(request *requests.Request) string {
+	if key := request.Metadata.GetString("header."+strings.ToLower(authenticator.header), ""); key != "" {
+		return key
+	}
+
+	if (authenticator.queryParameter == "") || !request.Data.Has(authenticator.queryParameter) {
+		return ""
+	}
+
+	var key = request.Data.Get(authenticator.queryParameter, nil)
+	request.Data.Unset(authenticator.queryParameter)
+
+	if values, isList := key.([]interface{}); isList && (len(values) > 0) {
+		return data.ToString(values[0], "")
+	}
+
+	return data.ToString(key, "")
+}
+
+const (
+	apiKeyLogTag = "apikey"
+)
+
+// An API key store with the entries read from the configuration, as "auth.apiKeys.<name>.hash" (the hex encoded key
+// hash), "auth.apiKeys.<name>.roles", "auth.apiKeys.<name>.scopes" and "auth.apiKeys.<name>.expiresAt" (RFC 3339).
+type ConfigApiKeyStore struct {
+	ApiKeyStore
+
+	keys []*ApiKey
+}
+
+// Creates a new API key store using the current configuration.
+func ConfigApiKeys() *ConfigApiKeyStore {
+	var entries = make(map[string]data.GenericMap)
+
+	for key, value := range config.GetSection("auth.apiKeys") {
+		var keyParts = strings.SplitN(key, ".", 2)
+
+		if len(keyParts) != 2 {
+			continue
+		}
+
+		if _, entryExists := entries[keyParts[0]]; !entryExists {
+			entries[keyParts[0]] = data.NewGenericMap()
+		}
+
+		entries[keyParts[0]].Set(keyParts[1], value)
+	}
+
+	var store = &ConfigApiKeyStore{
+		keys: make([]*ApiKey, 0, len(entries)),
+	}
+
+	for name, entry := range entries {
+		if apiKey, err := newApiKey(name, entry); err != nil {
+			log.Error(apiKeyLogTag, err)
+		} else {
+			store.keys = append(store.keys, apiKey)
+		}
+	}
+
+	log.Verbose(apiKeyLogTag, "%d keys in configuration", len(store.keys))
+	return store
+}
+
+func (store *ConfigApiKeyStore) Keys() []*ApiKey {
+	return store.keys
+}
+
+// An API key store reading a JSON file (reloaded when it changes) with the same entries as the configuration.
+type FileApiKeyStore struct {
+	ApiKeyStore
+
+	file *watchedFile
+	keys []*ApiKey
+}
+
+// Creates a new API key store reading the entries from the specified file.
+func FileApiKeys(fileName string) *FileApiKeyStore {
+	var store = &FileApiKeyStore{
+		keys: make([]*ApiKey, 0),
+	}
+
+	store.file = newWatchedFile(fileName, apiKeyLogTag, func(fileData []byte) (err error) {
+		var entries = make(map[string]data.GenericMap)
+
+		if err = json.Unmarshal(fileData, &entries); err != nil {
+			return fmt.Errorf("could not load %s: %v", fileName, err)
+		}
+
+		var keys = make([]*ApiKey, 0, len(entries))
+
+		for name, entry := range entries {
+			var apiKey *ApiKey
+
+			if apiKey, err = newApiKey(name, entry); err != nil {
+				return fmt.Errorf("could not load %s: %v", fileName, err)
+			}
+
+			keys = append(keys, apiKey)
+		}
+
+		log.Verbose(apiKeyLogTag, "%d keys in %s", len(keys), fileName)
+		store.keys = keys
+		return nil
+	})
+
+	return store
+}
+
+func (store *FileApiKeyStore) Keys() (keys []*ApiKey) {
+	store.file.read(func() {
+		keys = store.keys
+	})
+
+	return
+}
+
+func newApiKey(name string, entry data.GenericMap) (apiKey *ApiKey, err error) {
+	var fields = data.NewGenericMap()
+
+	// Configuration parameter names are lower case, so the entry field names are matched regardless of case.
+	for fieldName, value := range entry {
+		fields.Set(strings.ToLower(fieldName), value)
+	}
+
+	entry = fields
+	apiKey = &ApiKey{
+		Name:   name,
+		Roles:  claimStrings(entry.Get("roles", nil)),
+		Scopes: claimStrings(entry.Get("scopes", nil)),
+	}
+
+	if apiKey.Hash, err = hex.DecodeString(entry.GetString("hash", "")); err != nil || (len(apiKey.Hash) != sha256.Size) {
+		return nil, fmt.Errorf("bad hash for API key %s", name)
+	}
+
+	if expiresAt := entry.GetString("expiresat", ""); expiresAt != "" {
+		if apiKey.ExpiresAt, err = time.Parse(time.RFC3339, expiresAt); err != nil {
+			return nil, fmt.Errorf("bad expiration for API key %s: %v", name, err)
+		}
+	}
+
+	return apiKey, nil
+}
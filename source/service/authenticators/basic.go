@@ -0,0 +1,142 @@
+package authenticators
+
+import (
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"gogogo/config"
+	"gogogo/log"
+	"gogogo/requests"
+	"gogogo/service"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// An authenticator for HTTP Basic credentials, checked against a bcrypt, SHA or SSHA htpasswd file (auth.basic.file).
+type BasicAuthenticator struct {
+	service.Authenticator
+
+	realm     string
+	file      *watchedFile
+	passwords map[string]string
+}
+
+// Creates a new Basic authenticator.
+func Basic() *BasicAuthenticator {
+	var authenticator = &BasicAuthenticator{
+		realm:     config.GetString("auth.basic.realm", "Restricted"),
+		passwords: make(map[string]string),
+	}
+
+	var fileName = config.GetString("auth.basic.file", ".htpasswd")
+
+	log.Verbose(basicLogTag, "realm = %s", authenticator.realm)
+	log.Verbose(basicLogTag, "file = %s", fileName)
+
+	authenticator.file = newWatchedFile(fileName, basicLogTag, func(fileData []byte) error {
+		var passwords = make(map[string]string)
+
+		for lineNumber, line := range strings.Split(string(fileData), "\n") {
+			line = strings.TrimSpace(line)
+
+			if (line == "") || strings.HasPrefix(line, "#") {
+				continue
+			}
+
+			var lineParts = strings.SplitN(line, ":", 2)
+
+			if len(lineParts) != 2 {
+				return fmt.Errorf("bad entry in %s (line %d)", fileName, lineNumber+1)
+			}
+
+			passwords[lineParts[0]] = lineParts[1]
+		}
+
+		log.Verbose(basicLogTag, "%d users in %s", len(passwords), fileName)
+		authenticator.passwords = passwords
+		return nil
+	})
+
+	return authenticator
+}
+
+func (authenticator *BasicAuthenticator) Authenticate(request *requests.Request) (*requests.Identity, error) {
+	var authorization = request.Metadata.GetString("header.authorization", "")
+
+	if !strings.HasPrefix(authorization, "Basic ") {
+		return nil, nil
+	}
+
+	var credentials, err = base64.StdEncoding.DecodeString(strings.TrimPrefix(authorization, "Basic "))
+
+	if err != nil {
+		return nil, fmt.Errorf("bad basic credentials: %v", err)
+	}
+
+	var userName, password, hasPassword = strings.Cut(string(credentials), ":")
+
+	if !hasPassword {
+		return nil, errors.New("bad basic credentials")
+	}
+
+	var passwordHash string
+	var userExists bool
+
+	authenticator.file.read(func() {
+		passwordHash, userExists = authenticator.passwords[userName]
+	})
+
+	// Unknown users are checked against a dummy hash, so they take as long as known ones
+	if !userExists {
+		checkPassword(password, basicDummyHash)
+		return nil, fmt.Errorf("invalid password for %s", userName)
+	}
+
+	if !checkPassword(password, passwordHash) {
+		return nil, fmt.Errorf("invalid password for %s", userName)
+	}
+
+	return requests.NewIdentity(userName), nil
+}
+
+func (authenticator *BasicAuthenticator) Challenge() string {
+	return fmt.Sprintf("Basic realm=%q, charset=\"UTF-8\"", authenticator.realm)
+}
+
+const (
+	basicLogTag    = "basic"
+	basicDummyHash = "$2a$10$EacoyS3smvf9EAaDfLqinu6qQ8C2vYSswsZdd4RE71KFu7f1egTDK"
+)
+
+func checkPassword(password string, passwordHash string) bool {
+	switch {
+	case strings.HasPrefix(passwordHash, "$2y$"), strings.HasPrefix(passwordHash, "$2a$"), strings.HasPrefix(passwordHash, "$2b$"):
+		return bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(password)) == nil
+
+	case strings.HasPrefix(passwordHash, "{SHA}"):
+		var expectedHash, err = base64.StdEncoding.DecodeString(strings.TrimPrefix(passwordHash, "{SHA}"))
+
+		if err != nil {
+			return false
+		}
+
+		var actualHash = sha1.Sum([]byte(password))
+		return subtle.ConstantTimeCompare(actualHash[:], expectedHash) == 1
+
+	case strings.HasPrefix(passwordHash, "{SSHA}"):
+		var hashData, err = base64.StdEncoding.DecodeString(strings.TrimPrefix(passwordHash, "{SSHA}"))
+
+		if (err != nil) || (len(hashData) <= sha1.Size) {
+			return false
+		}
+
+		var actualHash = sha1.Sum(append([]byte(password), hashData[sha1.Size:]...))
+		return subtle.ConstantTimeCompare(actualHash[:], hashData[:sha1.Size]) == 1
+	}
+
+	log.Warning(basicLogTag, "unsupported password hash")
+	return false
+}
@@ -0,0 +1,100 @@
+package authenticators
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"gogogo/config"
+	"gogogo/log"
+	"gogogo/requests"
+	"gogogo/service"
+)
+
+// An authenticator for verified TLS client certificates, identified by their common name (or first SAN). The names can
+// also be granted as roles (auth.clientCertificate.namesAsRoles).
+type ClientCertificateAuthenticator struct {
+	service.Authenticator
+
+	namesAsRoles bool
+}
+
+// Creates a new client certificate authenticator.
+func ClientCertificate() *ClientCertificateAuthenticator {
+	var authenticator = &ClientCertificateAuthenticator{
+		namesAsRoles: config.GetBool("auth.clientCertificate.namesAsRoles", false),
+	}
+
+	log.Verbose(clientCertificateLogTag, "names as roles = %v", authenticator.namesAsRoles)
+
+	return authenticator
+}
+
+func (authenticator *ClientCertificateAuthenticator) Authenticate(request *requests.Request) (*requests.Identity, error) {
+	var certificate, hasCertificate = request.Metadata.Get("clientCertificate", nil).(*x509.Certificate)
+
+	if !hasCertificate {
+		return nil, nil
+	}
+
+	var names = certificateNames(certificate)
+
+	if len(names) == 0 {
+		return nil, fmt.Errorf("client certificate %s has no names", certificate.Subject)
+	}
+
+	var fingerprint = sha256.Sum256(certificate.Raw)
+	var identity = requests.NewIdentity(names[0])
+
+	if authenticator.namesAsRoles {
+		identity.Roles = append(identity.Roles, names...)
+	}
+
+	var uris = make([]string, 0, len(certificate.URIs))
+
+	for _, uri := range certificate.URIs {
+		uris = append(uris, uri.String())
+	}
+
+	var ipAddresses = make([]string, 0, len(certificate.IPAddresses))
+
+	for _, ipAddress := range certificate.IPAddresses {
+		ipAddresses = append(ipAddresses, ipAddress.String())
+	}
+
+	identity.Claims.Set("subject", certificate.Subject.String())
+	identity.Claims.Set("issuer", certificate.Issuer.String())
+	identity.Claims.Set("serialNumber", certificate.SerialNumber.String())
+	identity.Claims.Set("commonName", certificate.Subject.CommonName)
+	identity.Claims.Set("dnsNames", append(make([]string, 0, len(certificate.DNSNames)), certificate.DNSNames...))
+	identity.Claims.Set("uris", uris)
+	identity.Claims.Set("emailAddresses", append(make([]string, 0, len(certificate.EmailAddresses)), certificate.EmailAddresses...))
+	identity.Claims.Set("ipAddresses", ipAddresses)
+	identity.Claims.Set("fingerprint", hex.EncodeToString(fingerprint[:]))
+	identity.Claims.Set("notAfter", certificate.NotAfter.Unix())
+
+	log.Verbose(clientCertificateLogTag, "(%s) client certificate for %s", request.Id, identity.Subject)
+
+	return identity, nil
+}
+
+// Returns the names of a certificate: its common name first, then its DNS and URI SANs.
+func certificateNames(certificate *x509.Certificate) []string {
+	var names = make([]string, 0, 1+len(certificate.DNSNames)+len(certificate.URIs))
+
+	if certificate.Subject.CommonName != "" {
+		names = append(names, certificate.Subject.CommonName)
+	}
+
+	names = append(names, certificate.DNSNames...)
+
+	for _, uri := range certificate.URIs {
+		names = append(names, uri.String())
+	}
+
+	return names
+}
+
+const (
+	clientCertificateLogTag = "clientcert"
+)
@@ -0,0 +1,71 @@
+package authenticators
+
+import (
+	"gogogo/log"
+	"os"
+	"sync"
+	"time"
+)
+
+// A file that is reloaded when its modification time changes (checked at most once per second).
+type watchedFile struct {
+	fileName  string
+	logTag    string
+	load      func(fileData []byte) error
+	mutex     sync.Mutex
+	modTime   time.Time
+	lastCheck time.Time
+}
+
+func newWatchedFile(fileName string, logTag string, load func(fileData []byte) error) *watchedFile {
+	var file = &watchedFile{
+		fileName: fileName,
+		logTag:   logTag,
+		load:     load,
+	}
+
+	file.reload()
+	return file
+}
+
+// Runs the reader function with the file loaded, reloading it first if it changed. The loaded data must only be
+// accessed from reader functions.
+func (file *watchedFile) read(reader func()) {
+	file.mutex.Lock()
+	defer file.mutex.Unlock()
+
+	if time.Since(file.lastCheck) > time.Second {
+		file.reload()
+	}
+
+	reader()
+}
+
+func (file *watchedFile) reload() {
+	file.lastCheck = time.Now()
+
+	var fileInfo, err = os.Stat(file.fileName)
+
+	if err != nil {
+		log.Error(file.logTag, err)
+		return
+	}
+
+	if fileInfo.ModTime().Equal(file.modTime) {
+		return
+	}
+
+	var fileData []byte
+
+	if fileData, err = os.ReadFile(file.fileName); err == nil {
+		err = file.load(fileData)
+	}
+
+	if err != nil {
+		log.Error(file.logTag, err)
+		return
+	}
+
+	log.Information(file.logTag, "loaded %s", file.fileName)
+	file.modTime = fileInfo.ModTime()
+}
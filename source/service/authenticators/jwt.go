@@ -0,0 +1,443 @@
+package authenticators
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"gogogo/config"
+	"gogogo/data"
+	"gogogo/log"
+	"gogogo/requests"
+	"gogogo/service"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+)
+
+// An authenticator for HS256, RS256 or ES256 JWT bearer tokens (auth.jwt.secret, publicKeyFile and/or jwksFile).
+type JwtAuthenticator struct {
+	service.Authenticator
+
+	secret     []byte
+	publicKey  crypto.PublicKey
+	jwks       *jwksFile
+	issuer     string
+	audience   string
+	clockSkew  time.Duration
+	rolesClaim string
+	revoked    RevocationList
+}
+
+// Tells whether a token (identified by its "jti" claim) was revoked.
+type RevocationList interface {
+	IsRevoked(tokenId string) (bool, error)
+}
+
+// Creates a new JWT authenticator.
+func Jwt() *JwtAuthenticator {
+	var authenticator = &JwtAuthenticator{
+		secret:     []byte(config.GetString("auth.jwt.secret", "")),
+		issuer:     config.GetString("auth.jwt.issuer", ""),
+		audience:   config.GetString("auth.jwt.audience", ""),
+		clockSkew:  time.Duration(config.GetInt("auth.jwt.clockSkew", 30)) * time.Second,
+		rolesClaim: config.GetString("auth.jwt.rolesClaim", "roles"),
+	}
+
+	if publicKeyFile := config.GetString("auth.jwt.publicKeyFile", ""); publicKeyFile != "" {
+		var err error
+
+		if authenticator.publicKey, err = loadPublicKey(publicKeyFile); err != nil {
+			log.Error(jwtLogTag, err)
+		}
+	}
+
+	if jwksFileName := config.GetString("auth.jwt.jwksFile", ""); jwksFileName != "" {
+		authenticator.jwks = newJwksFile(jwksFileName)
+	}
+
+	log.Verbose(jwtLogTag, "issuer = %s", authenticator.issuer)
+	log.Verbose(jwtLogTag, "audience = %s", authenticator.audience)
+	log.Verbose(jwtLogTag, "clock skew = %v", authenticator.clockSkew)
+
+	return authenticator
+}
+
+// Sets the revocation list checked for tokens having a "jti" claim.
+func (authenticator *JwtAuthenticator) WithRevocationList(revoked RevocationList) *JwtAuthenticator {
+	authenticator.revoked = revoked
+	return authenticator
+}
+
+func (authenticator *JwtAuthenticator) Authenticate(request *requests.Request) (*requests.Identity, error) {
+	var token = request.Metadata.GetString("token", "")
+
+	if token == "" {
+		return nil, nil
+	}
+
+	var claims, err = authenticator.Verify(token)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var identity = requests.NewIdentity(claims.GetString("sub", ""), claimStrings(claims.Get(authenticator.rolesClaim, nil))...)
+	identity.Claims = claims
+
+	if claims.Has("scope") {
+		identity.Scopes = strings.Fields(claims.GetString("scope", ""))
+	} else {
+		identity.Scopes = claimStrings(claims.Get("scp", nil))
+	}
+
+	return identity, nil
+}
+
+func (authenticator *JwtAuthenticator) Challenge() string {
+	return "Bearer"
+}
+
+// Verifies a token signature and claims, returning the verified claims.
+func (authenticator *JwtAuthenticator) Verify(token string) (claims data.GenericMap, err error) {
+	var tokenParts = strings.Split(token, ".")
+
+	if len(tokenParts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+
+	var header = data.NewGenericMap()
+
+	if err = decodeTokenPart(tokenParts[0], &header); err != nil {
+		return nil, fmt.Errorf("bad token header: %v", err)
+	}
+
+	var signature []byte
+
+	if signature, err = base64.RawURLEncoding.DecodeString(tokenParts[2]); err != nil {
+		return nil, fmt.Errorf("bad token signature: %v", err)
+	}
+
+	var algorithm = header.GetString("alg", "")
+	var signedData = []byte(tokenParts[0] + "." + tokenParts[1])
+
+	if err = authenticator.verifySignature(algorithm, header.GetString("kid", ""), signedData, signature); err != nil {
+		return nil, err
+	}
+
+	claims = data.NewGenericMap()
+
+	if err = decodeTokenPart(tokenParts[1], &claims); err != nil {
+		return nil, fmt.Errorf("bad token claims: %v", err)
+	}
+
+	if err = authenticator.verifyClaims(claims); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+func (authenticator *JwtAuthenticator) verifySignature(algorithm string, keyId string, signedData []byte, signature []byte) error {
+	var digest = sha256.Sum256(signedData)
+
+	switch algorithm {
+	case "HS256":
+		if len(authenticator.secret) == 0 {
+			return errors.New("no secret for HS256 tokens")
+		}
+
+		var mac = hmac.New(sha256.New, authenticator.secret)
+		mac.Write(signedData)
+
+		if !hmac.Equal(mac.Sum(nil), signature) {
+			return errors.New("invalid token signature")
+		}
+
+	case "RS256":
+		var publicKey, isRsaKey = authenticator.key(keyId).(*rsa.PublicKey)
+
+		if !isRsaKey {
+			return fmt.Errorf("no RSA key for token (key id: %s)", keyId)
+		}
+
+		if rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, digest[:], signature) != nil {
+			return errors.New("invalid token signature")
+		}
+
+	case "ES256":
+		var publicKey, isEcdsaKey = authenticator.key(keyId).(*ecdsa.PublicKey)
+
+		if !isEcdsaKey {
+			return fmt.Errorf("no ECDSA key for token (key id: %s)", keyId)
+		}
+
+		if len(signature) != 64 {
+			return errors.New("invalid token signature")
+		}
+
+		var r = new(big.Int).SetBytes(signature[:32])
+		var s = new(big.Int).SetBytes(signature[32:])
+
+		if !ecdsa.Verify(publicKey, digest[:], r, s) {
+			return errors.New("invalid token signature")
+		}
+
+	default:
+		return fmt.Errorf("unsupported token algorithm: %s", algorithm)
+	}
+
+	return nil
+}
+
+func (authenticator *JwtAuthenticator) verifyClaims(claims data.GenericMap) error {
+	var now = time.Now()
+
+	if claims.Has("exp") && now.Add(-authenticator.clockSkew).Unix() >= claims.GetInt("exp", 0) {
+		return errors.New("token expired")
+	}
+
+	if claims.Has("nbf") && now.Add(authenticator.clockSkew).Unix() < claims.GetInt("nbf", 0) {
+		return errors.New("token not valid yet")
+	}
+
+	if (authenticator.revoked != nil) && claims.Has("jti") {
+		var isRevoked, err = authenticator.revoked.IsRevoked(claims.GetString("jti", ""))
+
+		if err != nil {
+			return fmt.Errorf("could not check token revocation: %v", err)
+		}
+
+		if isRevoked {
+			return errors.New("token revoked")
+		}
+	}
+
+	if (authenticator.issuer != "") && (claims.GetString("iss", "") != authenticator.issuer) {
+		return fmt.Errorf("unexpected token issuer: %s", claims.GetString("iss", ""))
+	}
+
+	if authenticator.audience != "" {
+		var audienceFound = false
+
+		for _, audience := range claimStrings(claims.Get("aud", nil)) {
+			if audience == authenticator.audience {
+				audienceFound = true
+				break
+			}
+		}
+
+		if !audienceFound {
+			return errors.New("unexpected token audience")
+		}
+	}
+
+	return nil
+}
+
+// Returns the public key with the specified key id. The JWKS keys are looked up first, falling back to the PEM key.
+func (authenticator *JwtAuthenticator) key(keyId string) crypto.PublicKey {
+	if authenticator.jwks != nil {
+		if publicKey := authenticator.jwks.key(keyId); publicKey != nil {
+			return publicKey
+		}
+	}
+
+	return authenticator.publicKey
+}
+
+const (
+	jwtLogTag = "jwt"
+)
+
+// Creates a token with the specified claims, signed with HS256.
+func SignJwt(claims data.GenericMap, secret []byte) (token string, err error) {
+	var headerData, claimsData []byte
+
+	if headerData, err = json.Marshal(data.GenericMap{"alg": "HS256", "typ": "JWT"}); err != nil {
+		return
+	}
+
+	if claimsData, err = json.Marshal(claims); err != nil {
+		return
+	}
+
+	token = base64.RawURLEncoding.EncodeToString(headerData) + "." + base64.RawURLEncoding.EncodeToString(claimsData)
+
+	var mac = hmac.New(sha256.New, secret)
+	mac.Write([]byte(token))
+
+	return token + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+func decodeTokenPart(tokenPart string, value interface{}) error {
+	var partData, err = base64.RawURLEncoding.DecodeString(tokenPart)
+
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(partData, value)
+}
+
+// Returns a claim value as a list of strings (claims like "aud" may be either a string or a list of strings).
+func claimStrings(claim interface{}) []string {
+	switch typedClaim := claim.(type) {
+	case string:
+		return []string{typedClaim}
+
+	case []interface{}:
+		var values = make([]string, 0, len(typedClaim))
+
+		for _, value := range typedClaim {
+			if stringValue, isString := value.(string); isString {
+				values = append(values, stringValue)
+			}
+		}
+
+		return values
+	}
+
+	return []string{}
+}
+
+func loadPublicKey(fileName string) (crypto.PublicKey, error) {
+	var fileData, err = os.ReadFile(fileName)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var block, _ = pem.Decode(fileData)
+
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data in %s", fileName)
+	}
+
+	if certificate, err := x509.ParseCertificate(block.Bytes); err == nil {
+		return certificate.PublicKey, nil
+	}
+
+	return x509.ParsePKIXPublicKey(block.Bytes)
+}
+
+// A JWKS file, reloaded when it changes.
+type jwksFile struct {
+	*watchedFile
+
+	keys map[string]crypto.PublicKey
+}
+
+func newJwksFile(fileName string) *jwksFile {
+	var file = &jwksFile{
+		keys: make(map[string]crypto.PublicKey),
+	}
+
+	file.watchedFile = newWatchedFile(fileName, jwtLogTag, func(fileData []byte) (err error) {
+		var keys map[string]crypto.PublicKey
+
+		if keys, err = parseJwks(fileData); err != nil {
+			return fmt.Errorf("could not load %s: %v", fileName, err)
+		}
+
+		log.Verbose(jwtLogTag, "%d keys in %s", len(keys), fileName)
+		file.keys = keys
+		return nil
+	})
+
+	return file
+}
+
+func (file *jwksFile) key(keyId string) (publicKey crypto.PublicKey) {
+	file.read(func() {
+		var keyExists bool
+
+		if publicKey, keyExists = file.keys[keyId]; keyExists {
+			return
+		}
+
+		if (keyId == "") && (len(file.keys) == 1) {
+			for _, publicKey = range file.keys {
+				return
+			}
+		}
+	})
+
+	return
+}
+
+func parseJwks(fileData []byte) (keys map[string]crypto.PublicKey, err error) {
+	var jwks struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Crv string `json:"crv"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+
+	if err = json.Unmarshal(fileData, &jwks); err != nil {
+		return
+	}
+
+	keys = make(map[string]crypto.PublicKey)
+
+	for _, jwk := range jwks.Keys {
+		switch jwk.Kty {
+		case "RSA":
+			var modulus, exponent []byte
+
+			if modulus, err = base64.RawURLEncoding.DecodeString(jwk.N); err != nil {
+				return nil, fmt.Errorf("bad key %s: %v", jwk.Kid, err)
+			}
+
+			if exponent, err = base64.RawURLEncoding.DecodeString(jwk.E); err != nil {
+				return nil, fmt.Errorf("bad key %s: %v", jwk.Kid, err)
+			}
+
+			keys[jwk.Kid] = &rsa.PublicKey{
+				N: new(big.Int).SetBytes(modulus),
+				E: int(new(big.Int).SetBytes(exponent).Int64()),
+			}
+
+		case "EC":
+			if jwk.Crv != "P-256" {
+				log.Warning(jwtLogTag, "skipping key %s: unsupported curve %s", jwk.Kid, jwk.Crv)
+				continue
+			}
+
+			var x, y []byte
+
+			if x, err = base64.RawURLEncoding.DecodeString(jwk.X); err != nil {
+				return nil, fmt.Errorf("bad key %s: %v", jwk.Kid, err)
+			}
+
+			if y, err = base64.RawURLEncoding.DecodeString(jwk.Y); err != nil {
+				return nil, fmt.Errorf("bad key %s: %v", jwk.Kid, err)
+			}
+
+			keys[jwk.Kid] = &ecdsa.PublicKey{
+				Curve: elliptic.P256(),
+				X:     new(big.Int).SetBytes(x),
+				Y:     new(big.Int).SetBytes(y),
+			}
+
+		default:
+			log.Warning(jwtLogTag, "skipping key %s: unsupported key type %s", jwk.Kid, jwk.Kty)
+		}
+	}
+
+	return
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"gogogo/log"
+	"gogogo/requests"
+	"strings"
+)
+
+// Decides whether an identity may access a route, returning the reason for the decision (which is logged).
+type Policy interface {
+	Evaluate(identity *requests.Identity, route *requests.Route) (isAllowed bool, reason string)
+}
+
+// The function signature for policies implemented as plain functions.
+type PolicyFunc func(identity *requests.Identity, route *requests.Route) (bool, string)
+
+func (policy PolicyFunc) Evaluate(identity *requests.Identity, route *requests.Route) (bool, string) {
+	return policy(identity, route)
+}
+
+// Replaces the authorization policy (the default one is RolesAndScopes).
+func SetPolicy(policy Policy) {
+	_policy = policy
+}
+
+// The default authorization policy: identities must have at least one of the route roles and all of the route scopes.
+func RolesAndScopes(identity *requests.Identity, route *requests.Route) (bool, string) {
+	if len(route.Roles) > 0 {
+		var roleFound = false
+
+		for _, role := range route.Roles {
+			if identity.HasRole(role) {
+				roleFound = true
+				break
+			}
+		}
+
+		if !roleFound {
+			return false, "missing role (any of " + strings.Join(route.Roles, ", ") + ")"
+		}
+	}
+
+	for _, scope := range route.Scopes {
+		if !identity.HasScope(scope) {
+			return false, "missing scope " + scope
+		}
+	}
+
+	return true, "roles and scopes granted"
+}
+
+// A middleware that evaluates the authorization policy for authenticated requests, rejecting the ones it denies with
+// the NotAuthorized status. Unauthenticated requests to routes that require roles or scopes are rejected with the
+// AuthenticationRequired status.
+func Authorize(next requests.Handler) requests.Handler {
+	return func(request *requests.Request, response *requests.Response) error {
+		if request.Identity == nil {
+			if (len(request.Route.Roles) > 0) || (len(request.Route.Scopes) > 0) {
+				log.Verbose(_logTag, "(%s) route %s:%s requires roles or scopes and the request is not authenticated", request.Id, strings.ToLower(request.Type.String()), request.Path)
+				requireAuthentication(response)
+				return nil
+			}
+
+			return next(request, response)
+		}
+
+		var isAllowed, reason = _policy.Evaluate(request.Identity, request.Route)
+
+		if !isAllowed {
+			log.Warning(_logTag, "(%s) access denied to %s for %s:%s: %s", request.Id, request.Identity.Subject, strings.ToLower(request.Type.String()), request.Path, reason)
+			response.Status = requests.NotAuthorized
+			return nil
+		}
+
+		log.Verbose(_logTag, "(%s) access granted to %s: %s", request.Id, request.Identity.Subject, reason)
+		return next(request, response)
+	}
+}
+
+var (
+	_policy Policy = PolicyFunc(RolesAndScopes)
+)
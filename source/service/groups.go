@@ -0,0 +1,121 @@
+package service
+
+import (
+	"gogogo/data/contract"
+	"gogogo/requests"
+	"strings"
+)
+
+// A route registrar that applies a path prefix, a default visibility, default contracts and a middleware chain to the
+// routes added through it.
+type RouteGroup struct {
+	prefix      string
+	isPublic    bool
+	contracts   []*contract.Contract
+	middlewares []Middleware
+	options     []RouteOption
+}
+
+// Creates a new route group with the specified path prefix. Routes added through the group are private unless the
+// group is made public.
+func Group(prefix string) *RouteGroup {
+	return &RouteGroup{
+		prefix:      strings.Trim(prefix, "/"),
+		isPublic:    false,
+		contracts:   make([]*contract.Contract, 0),
+		middlewares: make([]Middleware, 0),
+		options:     make([]RouteOption, 0),
+	}
+}
+
+// Creates a nested route group. The nested group inherits the group prefix, visibility, contracts, middlewares and
+// options.
+func (group *RouteGroup) Group(prefix string) *RouteGroup {
+	var nestedGroup = Group(group.path(prefix))
+
+	nestedGroup.isPublic = group.isPublic
+	nestedGroup.contracts = append(nestedGroup.contracts, group.contracts...)
+	nestedGroup.middlewares = append(nestedGroup.middlewares, group.middlewares...)
+	nestedGroup.options = append(nestedGroup.options, group.options...)
+
+	return nestedGroup
+}
+
+// Makes the routes added through the group public.
+func (group *RouteGroup) Public() *RouteGroup {
+	group.isPublic = true
+	return group
+}
+
+// Makes the routes added through the group private.
+func (group *RouteGroup) Private() *RouteGroup {
+	group.isPublic = false
+	return group
+}
+
+// Adds contracts whose fields are merged into the contracts of the routes added through the group.
+func (group *RouteGroup) Contract(contracts ...*contract.Contract) *RouteGroup {
+	group.contracts = append(group.contracts, contracts...)
+	return group
+}
+
+// Adds middlewares to the chain that wraps the handlers of the routes added through the group. Middlewares run in the
+// order they were added.
+func (group *RouteGroup) Use(middlewares ...Middleware) *RouteGroup {
+	group.middlewares = append(group.middlewares, middlewares...)
+	return group
+}
+
+// Adds options that are applied to the routes added through the group (before the route own options).
+func (group *RouteGroup) With(options ...RouteOption) *RouteGroup {
+	group.options = append(group.options, options...)
+	return group
+}
+
+func (group *RouteGroup) AddPull(path string, handler requests.Handler, contract *contract.Contract, options ...RouteOption) {
+	group.addRoute(requests.Pull, path, handler, contract, options)
+}
+
+func (group *RouteGroup) AddPush(path string, handler requests.Handler, contract *contract.Contract, options ...RouteOption) {
+	group.addRoute(requests.Push, path, handler, contract, options)
+}
+
+func (group *RouteGroup) AddUpdate(path string, handler requests.Handler, contract *contract.Contract, options ...RouteOption) {
+	group.addRoute(requests.Update, path, handler, contract, options)
+}
+
+func (group *RouteGroup) AddDelete(path string, handler requests.Handler, contract *contract.Contract, options ...RouteOption) {
+	group.addRoute(requests.Delete, path, handler, contract, options)
+}
+
+func (group *RouteGroup) AddReplace(path string, handler requests.Handler, contract *contract.Contract, options ...RouteOption) {
+	group.addRoute(requests.Replace, path, handler, contract, options)
+}
+
+func (group *RouteGroup) path(path string) string {
+	path = strings.Trim(path, "/")
+
+	if group.prefix == "" {
+		return path
+	}
+
+	if path == "" {
+		return group.prefix
+	}
+
+	return group.prefix + "/" + path
+}
+
+func (group *RouteGroup) addRoute(requestType requests.Type, path string, handler requests.Handler, routeContract *contract.Contract, options []RouteOption) {
+	if len(group.contracts) > 0 {
+		routeContract = contract.Merge(contract.Merge(group.contracts...), routeContract)
+	}
+
+	var routeOptions = make([]RouteOption, 0, len(group.options)+len(options)+1)
+
+	routeOptions = append(routeOptions, WithMiddleware(group.middlewares...))
+	routeOptions = append(routeOptions, group.options...)
+	routeOptions = append(routeOptions, options...)
+
+	addRoute(requestType, group.path(path), group.isPublic, handler, routeContract, routeOptions...)
+}
@@ -0,0 +1,184 @@
+package listeners
+
+import (
+	"errors"
+	"gogogo/config"
+	"gogogo/data"
+	"gogogo/log"
+	"gogogo/requests"
+	"gogogo/service"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// The CORS settings for a request, read from the "http.cors.*" parameters (which routes can override). CORS is disabled
+// if allowedOrigins (origins or patterns, like "https://*.example.com") is empty.
+type corsSettings struct {
+	allowedOrigins   []string
+	allowedMethods   []string
+	allowedHeaders   []string
+	exposedHeaders   []string
+	allowCredentials bool
+	maxAge           int64
+}
+
+// Credentials are never allowed with the "*" origin (which would let any site send credentialed requests).
+func newCorsSettings(route *requests.Route) (settings *corsSettings) {
+	settings = &corsSettings{
+		allowedOrigins:   data.ToStrings(routeSetting(route, "http.cors.allowedOrigins", nil), []string{}),
+		allowedMethods:   data.ToStrings(routeSetting(route, "http.cors.allowedMethods", nil), []string{}),
+		allowedHeaders:   data.ToStrings(routeSetting(route, "http.cors.allowedHeaders", nil), []string{"Content-Type", "Authorization"}),
+		exposedHeaders:   data.ToStrings(routeSetting(route, "http.cors.exposedHeaders", nil), []string{}),
+		allowCredentials: data.ToBool(routeSetting(route, "http.cors.allowCredentials", false), false),
+		maxAge:           data.ToInt(routeSetting(route, "http.cors.maxAge", 0), 0),
+	}
+
+	if containsFold(settings.allowedOrigins, "*") {
+		settings.allowCredentials = false
+	}
+
+	return
+}
+
+// Logs an error if the configured CORS settings allow credentials with the "*" origin (credentials are then ignored).
+func checkCorsSettings() {
+	var allowedOrigins = config.GetStrings("http.cors.allowedOrigins", []string{})
+
+	if config.GetBool("http.cors.allowCredentials", false) && containsFold(allowedOrigins, "*") {
+		log.Error(httpLogTag, errors.New("http.cors.allowCredentials is ignored with the \"*\" allowed origin"))
+	}
+}
+
+// Returns the Access-Control-Allow-Origin value for an origin, or an empty string if the origin is not allowed.
+func (settings *corsSettings) allowOrigin(origin string) string {
+	for _, allowedOrigin := range settings.allowedOrigins {
+		if allowedOrigin == "*" {
+			return "*"
+		}
+
+		if isMatch, _ := path.Match(allowedOrigin, origin); isMatch {
+			return origin
+		}
+	}
+
+	return ""
+}
+
+// Sets the CORS headers for a (non preflight) request. Responses vary by origin whenever CORS is enabled, so caches
+// do not serve responses without CORS headers to cross-origin requests (or the other way around).
+func writeCorsHeaders(request *requests.Request, httpResponse http.ResponseWriter, httpRequest *http.Request, route *requests.Route) {
+	var settings = newCorsSettings(route)
+
+	if len(settings.allowedOrigins) == 0 {
+		return
+	}
+
+	httpResponse.Header().Add("Vary", "Origin")
+
+	var origin = httpRequest.Header.Get("Origin")
+
+	if origin == "" {
+		return
+	}
+
+	var allowedOrigin = settings.allowOrigin(origin)
+
+	if allowedOrigin == "" {
+		log.Verbose(httpLogTag, "(%s) origin not allowed: %s", request.Id, origin)
+		return
+	}
+
+	httpResponse.Header().Set("Access-Control-Allow-Origin", allowedOrigin)
+
+	if settings.allowCredentials {
+		httpResponse.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+
+	if len(settings.exposedHeaders) > 0 {
+		httpResponse.Header().Set("Access-Control-Expose-Headers", strings.Join(settings.exposedHeaders, ", "))
+	}
+}
+
+// Checks whether a request is a CORS preflight request.
+func isPreflight(httpRequest *http.Request) bool {
+	return (httpRequest.Method == http.MethodOptions) && (httpRequest.Header.Get("Origin") != "") && (httpRequest.Header.Get("Access-Control-Request-Method") != "")
+}
+
+// Answers a CORS preflight request. The CORS headers are only set if the origin, method and headers are allowed.
+func writePreflight(request *requests.Request, httpResponse http.ResponseWriter, httpRequest *http.Request) {
+	var requestedMethod = httpRequest.Header.Get("Access-Control-Request-Method")
+	var path = httpRequest.URL.EscapedPath()
+	var route = service.FindRoute(requestTypeFromHttpMethod(requestedMethod), path)
+
+	log.Verbose(httpLogTag, "(%s) preflight for %s %s", request.Id, requestedMethod, path)
+
+	if route == nil {
+		log.Verbose(httpLogTag, "(%s) no route for preflight", request.Id)
+		httpResponse.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	var settings = newCorsSettings(route)
+	var allowedOrigin = settings.allowOrigin(httpRequest.Header.Get("Origin"))
+
+	httpResponse.Header().Add("Vary", "Origin")
+
+	if allowedOrigin == "" {
+		log.Verbose(httpLogTag, "(%s) origin not allowed: %s", request.Id, httpRequest.Header.Get("Origin"))
+		httpResponse.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	var allowedMethods = settings.allowedMethods
+
+	if len(allowedMethods) == 0 {
+		allowedMethods = httpMethodsFromRequestType(route.Type)
+	}
+
+	if !containsFold(allowedMethods, requestedMethod) {
+		log.Verbose(httpLogTag, "(%s) method not allowed: %s", request.Id, requestedMethod)
+		httpResponse.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	var allowedHeaders = strings.Join(settings.allowedHeaders, ", ")
+
+	for _, requestedHeader := range data.ToStrings(httpRequest.Header.Get("Access-Control-Request-Headers"), []string{}) {
+		if containsFold(settings.allowedHeaders, "*") {
+			allowedHeaders = httpRequest.Header.Get("Access-Control-Request-Headers")
+			break
+		}
+
+		if !containsFold(settings.allowedHeaders, requestedHeader) {
+			log.Verbose(httpLogTag, "(%s) header not allowed: %s", request.Id, requestedHeader)
+			httpResponse.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+
+	httpResponse.Header().Set("Access-Control-Allow-Origin", allowedOrigin)
+	httpResponse.Header().Set("Access-Control-Allow-Methods", strings.Join(allowedMethods, ", "))
+	httpResponse.Header().Set("Access-Control-Allow-Headers", allowedHeaders)
+
+	if settings.allowCredentials {
+		httpResponse.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+
+	if settings.maxAge > 0 {
+		httpResponse.Header().Set("Access-Control-Max-Age", strconv.FormatInt(settings.maxAge, 10))
+	}
+
+	httpResponse.WriteHeader(http.StatusNoContent)
+}
+
+func containsFold(values []string, value string) bool {
+	for _, currentValue := range values {
+		if strings.EqualFold(currentValue, value) {
+			return true
+		}
+	}
+
+	return false
+}
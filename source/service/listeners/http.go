@@ -3,14 +3,16 @@ package listeners
 import (
 	"context"
 	"encoding/base64"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"gogogo/config"
 	"gogogo/data"
+	"gogogo/data/codecs"
 	"gogogo/log"
 	"gogogo/requests"
 	"gogogo/service"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
 	"strings"
@@ -20,27 +22,76 @@ import (
 type HttpListener struct {
 	service.Listener
 
-	err    error
-	server http.Server
+	err            error
+	server         http.Server
+	certificate    *reloadingCertificate
+	redirectServer *http.Server
 }
 
 func Http() *HttpListener {
 	return &HttpListener{}
 }
 
+// Starts the listener. HTTPS is used when "http.tls.certFile" and "http.tls.keyFile" are set (see newTlsConfig for the
+// other TLS parameters), in which case "http.tls.redirectAddress" can be set to also listen for HTTP requests and
+// redirect them to HTTPS.
 func (listener *HttpListener) Start() (err error) {
 	var keepAlive = config.GetBool("http.keepAlive", false)
+	var certFile = config.GetString("http.tls.certFile", "")
+	var keyFile = config.GetString("http.tls.keyFile", "")
+	var defaultAddress = ":80"
 
-	listener.server.Addr = config.GetString("http.listenAddress", ":80")
+	if certFile != "" {
+		defaultAddress = ":443"
+	}
+
+	var maxHeaderBytes = config.GetInt("http.maxHeaderBytes", defaultMaxHeaderBytes)
+
+	listener.server.Addr = config.GetString("http.listenAddress", defaultAddress)
+	// The server limit is above the one checked by parseHeader, so the requests exceeding it get structured errors (the
+	// server answers the much larger ones on its own, with plain text errors)
+	listener.server.MaxHeaderBytes = int(maxHeaderBytes * 2)
 	listener.server.SetKeepAlivesEnabled(keepAlive)
 	listener.server.Handler = &httpHandler{}
 
 	log.Verbose(httpLogTag, "listen address = %s", listener.server.Addr)
 	log.Verbose(httpLogTag, "keep alive = %v", keepAlive)
+	log.Verbose(httpLogTag, "max header bytes = %d", maxHeaderBytes)
+
+	codecs.AddDecoder("multipart/form-data", newMultipartCodec())
+	checkCorsSettings()
+
+	if certFile != "" {
+		log.Verbose(httpLogTag, "certificate file = %s", certFile)
+		log.Verbose(httpLogTag, "key file = %s", keyFile)
+
+		if listener.certificate, err = newReloadingCertificate(certFile, keyFile); err != nil {
+			return
+		}
+
+		if listener.server.TLSConfig, err = newTlsConfig(listener.certificate); err != nil {
+			listener.certificate.stop()
+			listener.certificate = nil
+			return
+		}
+	}
 
 	log.Information(httpLogTag, "starting listener at '%s'", listener.server.Addr)
 
 	go listener.asyncStart()
+
+	if redirectAddress := config.GetString("http.tls.redirectAddress", ""); (redirectAddress != "") && (certFile != "") {
+		var _, httpsPort, _ = net.SplitHostPort(listener.server.Addr)
+
+		listener.redirectServer = &http.Server{
+			Addr:    redirectAddress,
+			Handler: &redirectHandler{httpsPort: httpsPort},
+		}
+
+		log.Information(httpLogTag, "starting HTTPS redirect listener at '%s'", redirectAddress)
+		go listener.asyncStartRedirect()
+	}
+
 	time.Sleep(time.Millisecond * 500)
 
 	return listener.err
@@ -49,16 +100,35 @@ func (listener *HttpListener) Start() (err error) {
 func (listener *HttpListener) Stop() {
 	log.Information(httpLogTag, "stopping")
 	listener.server.Shutdown(context.Background())
+
+	if listener.redirectServer != nil {
+		listener.redirectServer.Shutdown(context.Background())
+	}
+
+	if listener.certificate != nil {
+		listener.certificate.stop()
+	}
 }
 
 func (listener *HttpListener) asyncStart() {
-	listener.err = listener.server.ListenAndServe()
+	if listener.server.TLSConfig != nil {
+		listener.err = listener.server.ListenAndServeTLS("", "")
+	} else {
+		listener.err = listener.server.ListenAndServe()
+	}
 
 	if listener.err != http.ErrServerClosed {
 		log.Error(httpLogTag, listener.err)
 	}
 }
 
+func (listener *HttpListener) asyncStartRedirect() {
+	if err := listener.redirectServer.ListenAndServe(); err != http.ErrServerClosed {
+		listener.err = err
+		log.Error(httpLogTag, err)
+	}
+}
+
 const (
 	httpLogTag = "http"
 )
@@ -71,27 +141,72 @@ func (handler *httpHandler) ServeHTTP(httpResponse http.ResponseWriter, httpRequ
 	var request = requests.NewRequest()
 	log.Information(httpLogTag, "(%s) %s %s", request.Id, httpRequest.Method, httpRequest.RequestURI)
 
+	if isPreflight(httpRequest) {
+		writePreflight(request, httpResponse, httpRequest)
+		return
+	}
+
+	if httpRequest.Method == http.MethodOptions {
+		writeOptions(request, httpResponse, httpRequest.URL)
+		return
+	}
+
 	request.Type = requestTypeFromHttpMethod(httpRequest.Method)
 
 	if request.Type == requests.Unknown {
 		log.Warning(httpLogTag, "(%s) unsupported method: %s", request.Id, httpRequest.Method)
+		httpResponse.Header().Set("Allow", allowHeader(service.AllowedTypes(httpRequest.URL.EscapedPath())))
 		httpResponse.WriteHeader(http.StatusMethodNotAllowed)
 		return
 	}
 
+	var mediaType, encoder = codecs.Negotiate(httpRequest.Header.Get("Accept"))
+	var acceptsEncoder = encoder != nil
+	var isAcceptable = acceptsEncoder || codecs.Accepts(httpRequest.Header.Get("Accept"), "text/event-stream")
+
+	if encoder == nil {
+		mediaType, encoder = codecs.Negotiate("")
+	}
+
 	var writeError = func(status int, err error) {
 		log.Error(httpLogTag, fmt.Errorf("(%s) %v", request.Id, err))
 
+		var errorData = data.GenericMap{
+			"error": fmt.Sprintf("%v", err),
+		}
+
+		if exceededLimit, isLimitError := err.(*limitError); isLimitError {
+			status = exceededLimit.status
+			errorData["limit"] = exceededLimit.limit
+			errorData["maximum"] = exceededLimit.value
+		}
+
 		httpResponse.WriteHeader(status)
 
-		var jsonData, _ = json.Marshal(data.GenericMap{
-			"error": fmt.Sprintf("%v", err),
-		})
+		var errorBody, _ = encoder.Encode(errorData)
+
+		httpResponse.Write(errorBody)
+	}
+
+	httpResponse.Header().Set("Content-Type", mediaType)
+	httpResponse.Header().Add("Vary", "Accept")
 
-		httpResponse.Write(jsonData)
+	var route = service.FindRoute(request.Type, httpRequest.URL.EscapedPath())
+	writeCorsHeaders(request, httpResponse, httpRequest, route)
+
+	if remoteHost, _, err := net.SplitHostPort(httpRequest.RemoteAddr); err == nil {
+		request.Metadata.Set("remoteAddress", remoteHost)
 	}
 
-	httpResponse.Header().Set("Content-Type", "application/json")
+	if (httpRequest.TLS != nil) && (len(httpRequest.TLS.VerifiedChains) > 0) {
+		log.Verbose(httpLogTag, "(%s) client certificate: %s", request.Id, httpRequest.TLS.VerifiedChains[0][0].Subject)
+		request.Metadata.Set("clientCertificate", httpRequest.TLS.VerifiedChains[0][0])
+	}
+
+	if !isAcceptable {
+		writeError(http.StatusNotAcceptable, fmt.Errorf("no acceptable media type (available types: %s)", strings.Join(codecs.EncoderMediaTypes(), ", ")))
+		return
+	}
 
 	log.Verbose(httpLogTag, "(%s) parsing headers", request.Id)
 
@@ -107,18 +222,39 @@ func (handler *httpHandler) ServeHTTP(httpResponse http.ResponseWriter, httpRequ
 		return
 	}
 
-	if (request.Type == requests.Push) || (request.Type == requests.Update) {
+	// Requests without a route are answered with NotAllowed or ResourceNotFound, whatever their body
+	if hasBody(request.Type) && (route != nil) {
 		log.Verbose(httpLogTag, "(%s) extracting body", request.Id)
 
-		if err := extractBody(request, httpRequest.Body); err != nil {
+		if decoder, _ := codecs.FindDecoder(httpRequest.Header.Get("Content-Type")); decoder == nil {
+			writeError(http.StatusUnsupportedMediaType, fmt.Errorf("unsupported content-type: \"%s\"", httpRequest.Header.Get("Content-Type")))
+			return
+		}
+
+		var body = httpRequest.Body
+
+		if maxSize := maxBodySize(route); maxSize > 0 {
+			body = http.MaxBytesReader(httpResponse, body, maxSize)
+		}
+
+		if err := extractBody(request, body, httpRequest.Header.Get("Content-Type")); err != nil {
 			writeError(http.StatusUnprocessableEntity, err)
 			return
 		}
+
+		defer request.RemoveFiles()
 	}
 
+	request.SetContext(httpRequest.Context())
+
 	var response = requests.NewResponse(request.Id)
 	var requestError = service.HandleRequest(request, response)
 
+	if httpRequest.Context().Err() != nil {
+		log.Warning(httpLogTag, "(%s) client went away: %v", request.Id, httpRequest.Context().Err())
+		return
+	}
+
 	if requestError != nil {
 		writeError(http.StatusInternalServerError, requestError)
 		return
@@ -126,37 +262,76 @@ func (handler *httpHandler) ServeHTTP(httpResponse http.ResponseWriter, httpRequ
 
 	log.Information(httpLogTag, "(%s) got %s with %d data entries", request.Id, response.Status, len(response.Data))
 
+	if challenges, hasChallenges := response.Metadata.Get("challenges", nil).([]string); hasChallenges {
+		for _, challenge := range challenges {
+			httpResponse.Header().Add("WWW-Authenticate", challenge)
+		}
+	}
+
+	if rateLimit, hasRateLimit := response.Metadata.Get("rateLimit", nil).(data.GenericMap); hasRateLimit {
+		httpResponse.Header().Set("RateLimit-Limit", rateLimit.GetString("limit", ""))
+		httpResponse.Header().Set("RateLimit-Remaining", rateLimit.GetString("remaining", ""))
+		httpResponse.Header().Set("RateLimit-Reset", rateLimit.GetString("reset", ""))
+	}
+
+	if response.Metadata.Has("retryAfter") {
+		httpResponse.Header().Set("Retry-After", response.Metadata.GetString("retryAfter", ""))
+	}
+
+	if allowedTypes, hasAllowedTypes := response.Metadata.Get("allowedTypes", nil).([]requests.Type); hasAllowedTypes {
+		httpResponse.Header().Set("Allow", allowHeader(allowedTypes))
+	}
+
+	if (response.Stream != nil) && (response.Status == requests.OK) {
+		// The request context may be a route timeout one, which does not apply to streams
+		request.SetContext(httpRequest.Context())
+		writeEventStream(request, response, httpResponse, httpRequest)
+		return
+	}
+
+	// Clients only accepting event streams can only get streaming responses
+	if !acceptsEncoder {
+		writeError(http.StatusNotAcceptable, fmt.Errorf("no acceptable media type (available types: %s)", strings.Join(codecs.EncoderMediaTypes(), ", ")))
+		return
+	}
+
+	var responseBody, err = encoder.Encode(response.Data)
+
+	if err != nil {
+		writeError(http.StatusInternalServerError, err)
+		return
+	}
+
 	httpResponse.WriteHeader(httpStatusFromResponseStatus(response.Status))
-	var jsonData, _ = json.Marshal(response.Data)
-	httpResponse.Write(jsonData)
 
-	log.Verbose(httpLogTag, "(%s) %s", request.Id, base64.StdEncoding.EncodeToString(jsonData))
+	if httpRequest.Method == http.MethodHead {
+		return
+	}
+
+	httpResponse.Write(responseBody)
+
+	log.Verbose(httpLogTag, "(%s) %s", request.Id, base64.StdEncoding.EncodeToString(responseBody))
 
 }
 
 func parseHeader(request *requests.Request, header http.Header) (int, error) {
+	if maxBytes := config.GetInt("http.maxHeaderBytes", defaultMaxHeaderBytes); (maxBytes > 0) && (headerSize(header) > maxBytes) {
+		return http.StatusRequestHeaderFieldsTooLarge, &limitError{http.StatusRequestHeaderFieldsTooLarge, "http.maxHeaderBytes", maxBytes}
+	}
+
 	var contentType = header.Get("Content-Type")
 
 	if contentType != "" {
 		log.Verbose(httpLogTag, "(%s) content type: %s", request.Id, contentType)
 	}
 
-	if ((request.Type == requests.Push) || (request.Type == requests.Update)) && contentType != "application/json" {
-		return http.StatusUnsupportedMediaType, fmt.Errorf("unsupported content-type: \"%s\"", contentType)
+	for name, values := range header {
+		request.Metadata.Set("header."+strings.ToLower(name), values[0])
 	}
 
-	var tokenData = header.Get("Authorization")
-
-	if tokenData != "" {
-		log.Verbose(httpLogTag, "(%s) token data: %s", request.Id, tokenData)
-
-		var splitData = strings.Split(tokenData, "Bearer ")
-
-		if len(splitData) != 2 {
-			return http.StatusBadRequest, fmt.Errorf("bad authorization token data: %s", tokenData)
-		}
-
-		request.Metadata.Set("token", splitData[1])
+	if token, isBearer := strings.CutPrefix(header.Get("Authorization"), "Bearer "); isBearer {
+		log.Verbose(httpLogTag, "(%s) token data: %s", request.Id, token)
+		request.Metadata.Set("token", token)
 	}
 
 	return http.StatusOK, nil
@@ -165,35 +340,109 @@ func parseHeader(request *requests.Request, header http.Header) (int, error) {
 func parseUrl(request *requests.Request, url *url.URL) error {
 	request.Path = url.EscapedPath()
 
+	var queryData = data.NewGenericMap()
+
+	// Like form fields, single values are kept as is and repeated ones as lists
 	for key, values := range url.Query() {
 		log.Verbose(httpLogTag, "(%s) %s = %v", request.Id, key, values)
-		request.Data.Set(key, values)
+
+		if len(values) == 1 {
+			queryData.Set(key, values[0])
+			continue
+		}
+
+		var list = make([]interface{}, 0, len(values))
+
+		for _, value := range values {
+			list = append(list, value)
+		}
+
+		queryData.Set(key, list)
 	}
 
+	addTextFields(request, queryData)
+	request.Data.MergeWith(queryData)
+
 	return nil
 }
 
-func extractBody(request *requests.Request, body io.ReadCloser) (err error) {
-	var bodyData []byte
+// Decodes the request body with the decoder for its content type, adding the decoded data to the request data.
+func extractBody(request *requests.Request, body io.Reader, contentType string) (err error) {
+	var decoder, params = codecs.FindDecoder(contentType)
+	var decodedData data.GenericMap
+
+	if decodedData, err = decoder.Decode(body, params); err != nil {
+		var tooLarge *http.MaxBytesError
+
+		if errors.As(err, &tooLarge) {
+			err = &limitError{http.StatusRequestEntityTooLarge, "http.maxBodySize", tooLarge.Limit}
+		} else if errors.Is(err, codecs.ErrFileTooLarge) {
+			err = &limitError{http.StatusRequestEntityTooLarge, "http.uploads.maxFileSize", config.GetInt("http.uploads.maxFileSize", defaultMaxFileSize)}
+		} else if errors.Is(err, codecs.ErrTooManyFiles) {
+			err = &limitError{http.StatusRequestEntityTooLarge, "http.uploads.maxFiles", config.GetInt("http.uploads.maxFiles", defaultMaxFiles)}
+		}
 
-	if bodyData, err = io.ReadAll(body); err != nil {
 		return
 	}
 
-	log.Verbose(httpLogTag, "(%s) %s", request.Id, base64.StdEncoding.EncodeToString(bodyData))
+	log.Verbose(httpLogTag, "(%s) %d data entries in body", request.Id, len(decodedData))
 
-	var decodedData = data.NewGenericMap()
-	if err = json.Unmarshal(bodyData, &decodedData); err != nil {
-		return
+	if maxDepth := config.GetInt("http.maxJsonDepth", defaultMaxJsonDepth); (maxDepth > 0) && (int64(dataDepth(decodedData)) > maxDepth) {
+		return &limitError{http.StatusRequestEntityTooLarge, "http.maxJsonDepth", maxDepth}
+	}
+
+	switch decoder.(type) {
+	case *codecs.FormCodec, *codecs.MultipartCodec:
+		addTextFields(request, decodedData)
 	}
 
 	request.Data.MergeWith(decodedData)
 	return
 }
 
+// Adds the data fields to the "textFields" request metadata (the fields decoded from text, which contracts convert).
+func addTextFields(request *requests.Request, textData data.GenericMap) {
+	var textFields = request.Metadata.GetStrings("textFields", []string{})
+
+	for name := range textData {
+		textFields = append(textFields, name)
+	}
+
+	request.Metadata.Set("textFields", textFields)
+}
+
+// Answers an OPTIONS request with the methods allowed for the path.
+func writeOptions(request *requests.Request, httpResponse http.ResponseWriter, url *url.URL) {
+	var allowedTypes = service.AllowedTypes(url.EscapedPath())
+
+	if len(allowedTypes) == 0 {
+		log.Warning(httpLogTag, "(%s) no routes found for %s", request.Id, url.EscapedPath())
+		httpResponse.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	httpResponse.Header().Set("Allow", allowHeader(allowedTypes))
+	httpResponse.WriteHeader(http.StatusNoContent)
+}
+
+// Builds an Allow header value from the request types allowed for a path.
+func allowHeader(allowedTypes []requests.Type) string {
+	var methods = make([]string, 0, len(allowedTypes)+2)
+
+	for _, allowedType := range allowedTypes {
+		methods = append(methods, httpMethodsFromRequestType(allowedType)...)
+	}
+
+	return strings.Join(append(methods, http.MethodOptions), ", ")
+}
+
+func hasBody(requestType requests.Type) bool {
+	return (requestType == requests.Push) || (requestType == requests.Update) || (requestType == requests.Replace)
+}
+
 func requestTypeFromHttpMethod(httpMethod string) requests.Type {
 	switch httpMethod {
-	case http.MethodGet:
+	case http.MethodGet, http.MethodHead:
 		return requests.Pull
 	case http.MethodPost:
 		return requests.Push
@@ -201,11 +450,30 @@ func requestTypeFromHttpMethod(httpMethod string) requests.Type {
 		return requests.Update
 	case http.MethodDelete:
 		return requests.Delete
+	case http.MethodPut:
+		return requests.Replace
 	}
 
 	return requests.Unknown
 }
 
+func httpMethodsFromRequestType(requestType requests.Type) []string {
+	switch requestType {
+	case requests.Pull:
+		return []string{http.MethodGet, http.MethodHead}
+	case requests.Push:
+		return []string{http.MethodPost}
+	case requests.Update:
+		return []string{http.MethodPatch}
+	case requests.Delete:
+		return []string{http.MethodDelete}
+	case requests.Replace:
+		return []string{http.MethodPut}
+	}
+
+	return []string{}
+}
+
 func httpStatusFromResponseStatus(status requests.Status) int {
 	switch status {
 	case requests.OK:
@@ -226,6 +494,10 @@ func httpStatusFromResponseStatus(status requests.Status) int {
 		return http.StatusNotFound
 	case requests.ResourceAlreadyExists:
 		return http.StatusFound
+	case requests.Timeout:
+		return http.StatusGatewayTimeout
+	case requests.TooManyRequests:
+		return http.StatusTooManyRequests
 	}
 
 	return http.StatusInternalServerError
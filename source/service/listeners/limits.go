@@ -0,0 +1,81 @@
+package listeners
+
+import (
+	"fmt"
+	"gogogo/config"
+	"gogogo/data"
+	"gogogo/data/codecs"
+	"gogogo/requests"
+	"net/http"
+)
+
+// The size limits applied to HTTP requests (a limit of 0 or less disables the check). The maximum body size can be
+// overridden per route (so routes accepting file uploads can allow larger bodies).
+const (
+	defaultMaxBodySize    = 1 << 20
+	defaultMaxHeaderBytes = http.DefaultMaxHeaderBytes
+	defaultMaxJsonDepth   = 32
+	defaultMaxMemory      = 1 << 20
+	defaultMaxFileSize    = 10 << 20
+	defaultMaxFiles       = 10
+)
+
+// An error for requests exceeding one of the size limits. It is answered with its status and the limit in the error
+// data.
+type limitError struct {
+	status int
+	limit  string
+	value  int64
+}
+
+func (err *limitError) Error() string {
+	return fmt.Sprintf("%s exceeded (maximum is %d)", err.limit, err.value)
+}
+
+func maxBodySize(route *requests.Route) int64 {
+	return data.ToInt(routeSetting(route, "http.maxBodySize", defaultMaxBodySize), defaultMaxBodySize)
+}
+
+// Creates the multipart decoder from the "http.uploads.*" parameters (maxMemory, maxFileSize, maxFiles and tempDir).
+func newMultipartCodec() *codecs.MultipartCodec {
+	return codecs.Multipart().
+		WithMaxMemory(config.GetInt("http.uploads.maxMemory", defaultMaxMemory)).
+		WithMaxFileSize(config.GetInt("http.uploads.maxFileSize", defaultMaxFileSize)).
+		WithMaxFiles(config.GetInt("http.uploads.maxFiles", defaultMaxFiles)).
+		WithTempDir(config.GetString("http.uploads.tempDir", ""))
+}
+
+// Returns the size of the request header, counted the way it is sent ("Name: value\r\n" for each value).
+func headerSize(header http.Header) (size int64) {
+	for name, values := range header {
+		for _, value := range values {
+			size += int64(len(name) + len(value) + 4)
+		}
+	}
+
+	return
+}
+
+// Returns the nesting depth of the maps and lists in decoded data (as the decoders return them).
+func dataDepth(value interface{}) (maxDepth int) {
+	switch typedValue := value.(type) {
+	case data.GenericMap:
+		return dataDepth(map[string]interface{}(typedValue))
+
+	case map[string]interface{}:
+		for _, entryValue := range typedValue {
+			maxDepth = max(maxDepth, dataDepth(entryValue))
+		}
+
+		return maxDepth + 1
+
+	case []interface{}:
+		for _, item := range typedValue {
+			maxDepth = max(maxDepth, dataDepth(item))
+		}
+
+		return maxDepth + 1
+	}
+
+	return 0
+}
@@ -0,0 +1,18 @@
+package listeners
+
+import (
+	"gogogo/config"
+	"gogogo/requests"
+	"strings"
+)
+
+// Returns a configuration parameter value, looking it up in the route settings first (when there is a route).
+func routeSetting(route *requests.Route, paramName string, defaultValue interface{}) interface{} {
+	if route != nil {
+		if value, valueExists := route.Settings[strings.ToLower(paramName)]; valueExists {
+			return value
+		}
+	}
+
+	return config.Get(paramName, defaultValue)
+}
@@ -0,0 +1,166 @@
+package listeners
+
+import (
+	"encoding/json"
+	"fmt"
+	"gogogo/data"
+	"gogogo/log"
+	"gogogo/requests"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The event writer for Server-Sent Events streams.
+type sseWriter struct {
+	requests.EventWriter
+
+	request      *requests.Request
+	httpResponse http.ResponseWriter
+	controller   *http.ResponseController
+	lastEventId  string
+	mutex        sync.Mutex
+	isClosed     bool
+}
+
+// Streams a response as Server-Sent Events, using the "http.sse.retry" (in milliseconds) and "http.sse.heartbeat" (in
+// seconds, 0 disables it) parameters, which routes can override.
+func writeEventStream(request *requests.Request, response *requests.Response, httpResponse http.ResponseWriter, httpRequest *http.Request) {
+	var writer = &sseWriter{
+		request:      request,
+		httpResponse: httpResponse,
+		controller:   http.NewResponseController(httpResponse),
+		lastEventId:  httpRequest.Header.Get("Last-Event-ID"),
+	}
+
+	var retry = data.ToInt(routeSetting(request.Route, "http.sse.retry", 0), 0)
+	var heartbeat = data.ToInt(routeSetting(request.Route, "http.sse.heartbeat", 15), 15)
+
+	httpResponse.Header().Set("Content-Type", "text/event-stream")
+	httpResponse.Header().Set("Cache-Control", "no-cache")
+	httpResponse.Header().Set("X-Accel-Buffering", "no")
+	httpResponse.WriteHeader(http.StatusOK)
+
+	log.Information(httpLogTag, "(%s) streaming events (last event ID: %s)", request.Id, writer.lastEventId)
+
+	if retry > 0 {
+		writer.write(fmt.Sprintf("retry: %d\n\n", retry))
+	} else {
+		writer.write(": stream\n\n")
+	}
+
+	var streamDone = make(chan struct{})
+
+	if heartbeat > 0 {
+		go writer.sendHeartbeats(time.Duration(heartbeat)*time.Second, streamDone)
+	}
+
+	var err = response.Stream(writer)
+	close(streamDone)
+
+	if (err != nil) && (err != requests.ErrStreamClosed) {
+		log.Error(httpLogTag, fmt.Errorf("(%s) stream failed: %v", request.Id, err))
+		writer.Send(&requests.Event{Name: "error", Data: err.Error()})
+	}
+
+	writer.close()
+
+	log.Information(httpLogTag, "(%s) stream ended", request.Id)
+}
+
+func (writer *sseWriter) Send(event *requests.Event) error {
+	var message strings.Builder
+
+	if event.Id != "" {
+		message.WriteString("id: " + sseField(event.Id) + "\n")
+	}
+
+	if event.Name != "" {
+		message.WriteString("event: " + sseField(event.Name) + "\n")
+	}
+
+	if event.Retry > 0 {
+		message.WriteString(fmt.Sprintf("retry: %d\n", event.Retry.Milliseconds()))
+	}
+
+	var eventData string
+
+	if stringData, isString := event.Data.(string); isString {
+		eventData = stringData
+	} else if event.Data != nil {
+		var jsonData, err = json.Marshal(event.Data)
+
+		if err != nil {
+			return err
+		}
+
+		eventData = string(jsonData)
+	}
+
+	for _, line := range strings.Split(strings.ReplaceAll(eventData, "\r\n", "\n"), "\n") {
+		message.WriteString("data: " + line + "\n")
+	}
+
+	message.WriteString("\n")
+	return writer.write(message.String())
+}
+
+func (writer *sseWriter) LastEventId() string {
+	return writer.lastEventId
+}
+
+// Writes to the stream and flushes it, failing with ErrStreamClosed if the client went away.
+func (writer *sseWriter) write(message string) (err error) {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if writer.isClosed || (writer.request.Context().Err() != nil) {
+		return requests.ErrStreamClosed
+	}
+
+	if _, err = writer.httpResponse.Write([]byte(message)); err == nil {
+		err = writer.controller.Flush()
+	}
+
+	if err != nil {
+		log.Warning(httpLogTag, "(%s) stream write failed: %v", writer.request.Id, err)
+		return requests.ErrStreamClosed
+	}
+
+	return nil
+}
+
+// Closes the writer once the stream ends, waiting for the current write (the response must not be written to after the
+// handler returns, but heartbeats or stream goroutines may still try to).
+func (writer *sseWriter) close() {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	writer.isClosed = true
+}
+
+func (writer *sseWriter) sendHeartbeats(interval time.Duration, streamDone chan struct{}) {
+	var ticker = time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if writer.write(": heartbeat\n\n") != nil {
+				return
+			}
+
+		case <-streamDone:
+			return
+
+		case <-writer.request.Context().Done():
+			return
+		}
+	}
+}
+
+// Removes line breaks from event fields (which would end the field).
+func sseField(value string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(value)
+}
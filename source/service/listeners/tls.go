@@ -0,0 +1,242 @@
+package listeners
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"gogogo/config"
+	"gogogo/log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// A certificate that is reloaded when its files change (checked at most once per second) or when the process gets a
+// SIGHUP signal.
+type reloadingCertificate struct {
+	certFile      string
+	keyFile       string
+	mutex         sync.Mutex
+	certificate   *tls.Certificate
+	modTime       time.Time
+	lastCheck     time.Time
+	signalChannel chan os.Signal
+}
+
+func newReloadingCertificate(certFile string, keyFile string) (certificate *reloadingCertificate, err error) {
+	certificate = &reloadingCertificate{
+		certFile:      certFile,
+		keyFile:       keyFile,
+		signalChannel: make(chan os.Signal, 1),
+	}
+
+	if err = certificate.reload(true); err != nil {
+		return nil, err
+	}
+
+	signal.Notify(certificate.signalChannel, syscall.SIGHUP)
+	go certificate.reloadOnSignal()
+
+	return
+}
+
+// Returns the current certificate, reloading it first if its files changed (to be used as tls.Config.GetCertificate).
+func (certificate *reloadingCertificate) get(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	certificate.mutex.Lock()
+	defer certificate.mutex.Unlock()
+
+	if time.Since(certificate.lastCheck) > time.Second {
+		certificate.reload(false)
+	}
+
+	return certificate.certificate, nil
+}
+
+func (certificate *reloadingCertificate) stop() {
+	signal.Stop(certificate.signalChannel)
+	close(certificate.signalChannel)
+}
+
+func (certificate *reloadingCertificate) reloadOnSignal() {
+	for range certificate.signalChannel {
+		log.Information(tlsLogTag, "reloading certificate (SIGHUP)")
+
+		certificate.mutex.Lock()
+		certificate.reload(true)
+		certificate.mutex.Unlock()
+	}
+}
+
+// Loads the certificate if any of its files changed since the last load (or always, when forced). The current
+// certificate is kept if the new one can't be loaded.
+func (certificate *reloadingCertificate) reload(force bool) (err error) {
+	certificate.lastCheck = time.Now()
+
+	var modTime time.Time
+
+	for _, fileName := range []string{certificate.certFile, certificate.keyFile} {
+		var fileInfo os.FileInfo
+
+		if fileInfo, err = os.Stat(fileName); err != nil {
+			log.Error(tlsLogTag, err)
+			return
+		}
+
+		if fileInfo.ModTime().After(modTime) {
+			modTime = fileInfo.ModTime()
+		}
+	}
+
+	if !force && modTime.Equal(certificate.modTime) {
+		return
+	}
+
+	var loadedCertificate tls.Certificate
+
+	if loadedCertificate, err = tls.LoadX509KeyPair(certificate.certFile, certificate.keyFile); err != nil {
+		log.Error(tlsLogTag, err)
+		return
+	}
+
+	log.Information(tlsLogTag, "loaded certificate %s", certificate.certFile)
+
+	certificate.certificate = &loadedCertificate
+	certificate.modTime = modTime
+	return
+}
+
+// Builds the TLS configuration from the "http.tls.*" parameters: minVersion, cipherSuites and, for mutual TLS,
+// clientCaFile and clientAuth ("require" or "optional").
+func newTlsConfig(certificate *reloadingCertificate) (tlsConfig *tls.Config, err error) {
+	tlsConfig = &tls.Config{
+		GetCertificate: certificate.get,
+	}
+
+	var minVersion = config.GetString("http.tls.minVersion", "1.2")
+
+	if tlsConfig.MinVersion, err = tlsVersion(minVersion); err != nil {
+		return nil, err
+	}
+
+	log.Verbose(tlsLogTag, "min version = %s", minVersion)
+
+	for _, cipherSuiteName := range config.GetStrings("http.tls.cipherSuites", []string{}) {
+		var cipherSuiteId, err = cipherSuite(cipherSuiteName)
+
+		if err != nil {
+			return nil, err
+		}
+
+		log.Verbose(tlsLogTag, "cipher suite = %s", cipherSuiteName)
+		tlsConfig.CipherSuites = append(tlsConfig.CipherSuites, cipherSuiteId)
+	}
+
+	if clientCaFile := config.GetString("http.tls.clientCaFile", ""); clientCaFile != "" {
+		var clientAuth = config.GetString("http.tls.clientAuth", "require")
+
+		if tlsConfig.ClientAuth, err = tlsClientAuth(clientAuth); err != nil {
+			return nil, err
+		}
+
+		if tlsConfig.ClientCAs, err = loadCertificatePool(clientCaFile); err != nil {
+			return nil, err
+		}
+
+		log.Verbose(tlsLogTag, "client CA file = %s", clientCaFile)
+		log.Verbose(tlsLogTag, "client auth = %s", clientAuth)
+	}
+
+	return
+}
+
+func tlsClientAuth(clientAuth string) (tls.ClientAuthType, error) {
+	switch clientAuth {
+	case "require":
+		return tls.RequireAndVerifyClientCert, nil
+	case "optional":
+		return tls.VerifyClientCertIfGiven, nil
+	}
+
+	return tls.NoClientCert, fmt.Errorf("unknown client auth: \"%s\"", clientAuth)
+}
+
+func loadCertificatePool(fileName string) (pool *x509.CertPool, err error) {
+	var fileData []byte
+
+	if fileData, err = os.ReadFile(fileName); err != nil {
+		return
+	}
+
+	pool = x509.NewCertPool()
+
+	if !pool.AppendCertsFromPEM(fileData) {
+		return nil, fmt.Errorf("no certificates found in %s", fileName)
+	}
+
+	return
+}
+
+func tlsVersion(version string) (uint16, error) {
+	switch version {
+	case "1.0":
+		return tls.VersionTLS10, nil
+	case "1.1":
+		return tls.VersionTLS11, nil
+	case "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	}
+
+	return 0, fmt.Errorf("unknown TLS version: \"%s\"", version)
+}
+
+func cipherSuite(name string) (uint16, error) {
+	for _, cipherSuite := range tls.CipherSuites() {
+		if strings.EqualFold(cipherSuite.Name, name) {
+			return cipherSuite.ID, nil
+		}
+	}
+
+	for _, cipherSuite := range tls.InsecureCipherSuites() {
+		if strings.EqualFold(cipherSuite.Name, name) {
+			log.Warning(tlsLogTag, "insecure cipher suite: %s", cipherSuite.Name)
+			return cipherSuite.ID, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown cipher suite: \"%s\"", name)
+}
+
+// A handler redirecting HTTP requests to the same URL with HTTPS (on the HTTPS listener port).
+type redirectHandler struct {
+	http.Handler
+
+	httpsPort string
+}
+
+func (handler *redirectHandler) ServeHTTP(httpResponse http.ResponseWriter, httpRequest *http.Request) {
+	var host = httpRequest.Host
+
+	if hostName, _, err := net.SplitHostPort(host); err == nil {
+		host = hostName
+	}
+
+	if handler.httpsPort != "" && handler.httpsPort != "443" {
+		host = net.JoinHostPort(host, handler.httpsPort)
+	}
+
+	var location = "https://" + host + httpRequest.URL.RequestURI()
+
+	log.Verbose(tlsLogTag, "redirecting %s %s to %s", httpRequest.Method, httpRequest.RequestURI, location)
+	http.Redirect(httpResponse, httpRequest, location, http.StatusPermanentRedirect)
+}
+
+const (
+	tlsLogTag = "tls"
+)
@@ -0,0 +1,627 @@
+package listeners
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gogogo/config"
+	"gogogo/data"
+	"gogogo/log"
+	"gogogo/requests"
+	"gogogo/service"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+)
+
+// A listener for WebSocket connections. Clients send requests as JSON messages, like {"id": 1, "type": "pull",
+// "path": "notes"}, and get back responses with the same ID, like {"id": 1, "status": "OK", "data": {...}}.
+type WebSocketListener struct {
+	service.Listener
+
+	err            error
+	server         http.Server
+	path           string
+	allowedOrigins []string
+	maxMessageSize int64
+	maxDepth       int64
+	maxRequests    int64
+	pingInterval   time.Duration
+	connections    sync.Map
+}
+
+// Creates a new WebSocket listener.
+func WebSocket() *WebSocketListener {
+	return &WebSocketListener{}
+}
+
+// Starts the listener, using the "websocket.*" parameters (limits and intervals of 0 or less disable them).
+func (listener *WebSocketListener) Start() (err error) {
+	listener.server.Addr = config.GetString("websocket.listenAddress", ":8081")
+	listener.path = "/" + strings.TrimPrefix(config.GetString("websocket.path", "/"), "/")
+	listener.allowedOrigins = config.GetStrings("websocket.allowedOrigins", []string{})
+	listener.maxMessageSize = config.GetInt("websocket.maxMessageSize", defaultMaxMessageSize)
+	listener.maxDepth = config.GetInt("websocket.maxJsonDepth", defaultMaxJsonDepth)
+	listener.maxRequests = config.GetInt("websocket.maxConcurrentRequests", defaultMaxConcurrentRequests)
+	listener.pingInterval = time.Duration(config.GetInt("websocket.pingInterval", 30)) * time.Second
+	listener.server.Handler = listener
+
+	log.Verbose(webSocketLogTag, "listen address = %s", listener.server.Addr)
+	log.Verbose(webSocketLogTag, "path = %s", listener.path)
+	log.Verbose(webSocketLogTag, "allowed origins = %v", listener.allowedOrigins)
+	log.Verbose(webSocketLogTag, "max message size = %d", listener.maxMessageSize)
+	log.Verbose(webSocketLogTag, "max concurrent requests = %d", listener.maxRequests)
+	log.Verbose(webSocketLogTag, "ping interval = %v", listener.pingInterval)
+
+	log.Information(webSocketLogTag, "starting listener at '%s'", listener.server.Addr)
+
+	go listener.asyncStart()
+	time.Sleep(time.Millisecond * 500)
+
+	return listener.err
+}
+
+func (listener *WebSocketListener) Stop() {
+	log.Information(webSocketLogTag, "stopping")
+	listener.server.Shutdown(context.Background())
+
+	for _, connection := range listener.Connections() {
+		connection.close(wsGoingAway, "server stopping")
+	}
+}
+
+// Returns the open connections.
+func (listener *WebSocketListener) Connections() []*WebSocketConnection {
+	var connections = make([]*WebSocketConnection, 0)
+
+	listener.connections.Range(func(_ interface{}, connection interface{}) bool {
+		connections = append(connections, connection.(*WebSocketConnection))
+		return true
+	})
+
+	return connections
+}
+
+// Returns an open connection (handlers get the ID of the connection of their request in the "connectionId" request
+// metadata), or nil if there is no open connection with the ID.
+func (listener *WebSocketListener) Connection(connectionId string) *WebSocketConnection {
+	if connection, connectionExists := listener.connections.Load(connectionId); connectionExists {
+		return connection.(*WebSocketConnection)
+	}
+
+	return nil
+}
+
+// Pushes a message to all the open connections.
+func (listener *WebSocketListener) Broadcast(name string, pushData interface{}) {
+	for _, connection := range listener.Connections() {
+		connection.Push(name, pushData)
+	}
+}
+
+func (listener *WebSocketListener) asyncStart() {
+	listener.err = listener.server.ListenAndServe()
+
+	if listener.err != http.ErrServerClosed {
+		log.Error(webSocketLogTag, listener.err)
+	}
+}
+
+const (
+	webSocketLogTag              = "websocket"
+	webSocketGuid                = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	defaultMaxMessageSize        = 1 << 20
+	defaultMaxConcurrentRequests = 16
+	wsWriteTimeout               = 10 * time.Second
+)
+
+// Answers the opening handshake and serves the connection.
+func (listener *WebSocketListener) ServeHTTP(httpResponse http.ResponseWriter, httpRequest *http.Request) {
+	log.Information(webSocketLogTag, "%s %s from %s", httpRequest.Method, httpRequest.RequestURI, httpRequest.RemoteAddr)
+
+	if httpRequest.URL.Path != listener.path {
+		httpResponse.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	if (httpRequest.Method != http.MethodGet) ||
+		!headerHasToken(httpRequest.Header, "Connection", "upgrade") ||
+		!headerHasToken(httpRequest.Header, "Upgrade", "websocket") ||
+		(httpRequest.Header.Get("Sec-WebSocket-Key") == "") {
+		log.Warning(webSocketLogTag, "not a WebSocket handshake")
+		httpResponse.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if httpRequest.Header.Get("Sec-WebSocket-Version") != "13" {
+		log.Warning(webSocketLogTag, "unsupported version: %s", httpRequest.Header.Get("Sec-WebSocket-Version"))
+		httpResponse.Header().Set("Sec-WebSocket-Version", "13")
+		httpResponse.WriteHeader(http.StatusUpgradeRequired)
+		return
+	}
+
+	if !listener.isOriginAllowed(httpRequest) {
+		log.Warning(webSocketLogTag, "origin not allowed: %s", httpRequest.Header.Get("Origin"))
+		httpResponse.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	var conn, readWriter, err = http.NewResponseController(httpResponse).Hijack()
+
+	if err != nil {
+		log.Error(webSocketLogTag, err)
+		httpResponse.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	var acceptHash = sha1.Sum([]byte(httpRequest.Header.Get("Sec-WebSocket-Key") + webSocketGuid))
+
+	readWriter.WriteString("HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(acceptHash[:]) + "\r\n\r\n")
+
+	if err = readWriter.Flush(); err != nil {
+		log.Error(webSocketLogTag, err)
+		conn.Close()
+		return
+	}
+
+	var connection = newWebSocketConnection(listener, conn, readWriter.Reader, httpRequest)
+
+	listener.connections.Store(connection.Id, connection)
+	defer listener.connections.Delete(connection.Id)
+
+	log.Information(webSocketLogTag, "(%s) connection opened", connection.Id)
+	connection.serve()
+	log.Information(webSocketLogTag, "(%s) connection closed", connection.Id)
+}
+
+// Checks whether the handshake origin is allowed. Handshakes without an origin (which browsers always send) and from
+// the listener host are always allowed.
+func (listener *WebSocketListener) isOriginAllowed(httpRequest *http.Request) bool {
+	var origin = httpRequest.Header.Get("Origin")
+
+	if origin == "" {
+		return true
+	}
+
+	for _, allowedOrigin := range listener.allowedOrigins {
+		if isMatch, _ := path.Match(allowedOrigin, origin); isMatch {
+			return true
+		}
+	}
+
+	if originUrl, err := url.Parse(origin); err == nil {
+		return strings.EqualFold(originUrl.Host, httpRequest.Host)
+	}
+
+	return false
+}
+
+// Checks whether a header has a token (in its comma separated values).
+func headerHasToken(header http.Header, name string, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, valueToken := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(valueToken), token) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// An open WebSocket connection.
+type WebSocketConnection struct {
+	Id       string
+	Metadata data.GenericMap // The handshake request metadata ("remoteAddress" and "header.<name>" entries).
+
+	listener     *WebSocketListener
+	conn         net.Conn
+	reader       *bufio.Reader
+	requestSlots chan struct{}
+	writeMutex   sync.Mutex
+	context      context.Context
+	cancel       context.CancelFunc
+	closeOnce    sync.Once
+	identity     atomic.Pointer[requests.Identity]
+}
+
+func newWebSocketConnection(listener *WebSocketListener, conn net.Conn, reader *bufio.Reader, httpRequest *http.Request) *WebSocketConnection {
+	var randomBytes [16]byte
+	rand.Read(randomBytes[:])
+
+	var connection = &WebSocketConnection{
+		Id:       hex.EncodeToString(randomBytes[:]),
+		Metadata: data.NewGenericMap(),
+		listener: listener,
+		conn:     conn,
+		reader:   reader,
+	}
+
+	connection.context, connection.cancel = context.WithCancel(context.Background())
+
+	if listener.maxRequests > 0 {
+		connection.requestSlots = make(chan struct{}, listener.maxRequests)
+	}
+
+	if remoteHost, _, err := net.SplitHostPort(conn.RemoteAddr().String()); err == nil {
+		connection.Metadata.Set("remoteAddress", remoteHost)
+	}
+
+	for name, values := range httpRequest.Header {
+		connection.Metadata.Set("header."+strings.ToLower(name), values[0])
+	}
+
+	return connection
+}
+
+// Returns the identity of the last authenticated request sent on the connection (or nil), so messages can be pushed
+// to the connections of specific clients.
+func (connection *WebSocketConnection) Identity() *requests.Identity {
+	return connection.identity.Load()
+}
+
+// Pushes a message to the client.
+func (connection *WebSocketConnection) Push(name string, pushData interface{}) error {
+	return connection.send(data.GenericMap{
+		"push": name,
+		"data": pushData,
+	})
+}
+
+// Reads messages until the connection is closed, handling each request in its own goroutine (requests beyond the
+// concurrent requests limit are answered with the TooManyRequests status).
+func (connection *WebSocketConnection) serve() {
+	var pingDone = make(chan struct{})
+	defer close(pingDone)
+
+	if connection.listener.pingInterval > 0 {
+		go connection.sendPings(pingDone)
+	}
+
+	for {
+		var message, err = connection.readMessage()
+
+		if err != nil {
+			var closeError *wsCloseError
+
+			if errors.As(err, &closeError) {
+				log.Warning(webSocketLogTag, "(%s) %v", connection.Id, err)
+				connection.close(closeError.code, closeError.reason)
+			} else if err != errWsClosed {
+				log.Verbose(webSocketLogTag, "(%s) read failed: %v", connection.Id, err)
+				connection.close(wsGoingAway, "")
+			}
+
+			return
+		}
+
+		if connection.requestSlots == nil {
+			go connection.handleMessage(message)
+			continue
+		}
+
+		select {
+		case connection.requestSlots <- struct{}{}:
+			go func() {
+				defer func() { <-connection.requestSlots }()
+				connection.handleMessage(message)
+			}()
+
+		default:
+			connection.rejectMessage(message)
+		}
+	}
+}
+
+// Reads a (possibly fragmented) text message, answering the control frames read meanwhile.
+func (connection *WebSocketConnection) readMessage() (message []byte, err error) {
+	var isFragmented = false
+
+	for {
+		if connection.listener.pingInterval > 0 {
+			connection.conn.SetReadDeadline(time.Now().Add(connection.listener.pingInterval * 2))
+		}
+
+		var frame *wsFrame
+
+		if frame, err = readWsFrame(connection.reader, connection.listener.maxMessageSize); err != nil {
+			return
+		}
+
+		switch frame.opcode {
+		case wsPing:
+			connection.write(wsPong, frame.payload)
+			continue
+
+		case wsPong:
+			continue
+
+		case wsClose:
+			var code = wsNoStatusReceived
+
+			if len(frame.payload) >= 2 {
+				code = int(frame.payload[0])<<8 | int(frame.payload[1])
+			}
+
+			log.Verbose(webSocketLogTag, "(%s) client closed the connection (%d)", connection.Id, code)
+			connection.close(wsNormalClosure, "")
+			return nil, errWsClosed
+
+		case wsText, wsBinary:
+			if isFragmented {
+				return nil, &wsCloseError{wsProtocolError, "expected a continuation frame"}
+			}
+
+			if frame.opcode == wsBinary {
+				return nil, &wsCloseError{wsUnsupportedData, "binary messages are not supported"}
+			}
+
+		case wsContinuation:
+			if !isFragmented {
+				return nil, &wsCloseError{wsProtocolError, "unexpected continuation frame"}
+			}
+
+		default:
+			return nil, &wsCloseError{wsProtocolError, fmt.Sprintf("unknown opcode %d", frame.opcode)}
+		}
+
+		if (connection.listener.maxMessageSize > 0) && (int64(len(message)+len(frame.payload)) > connection.listener.maxMessageSize) {
+			return nil, &wsCloseError{wsMessageTooBig, "message too big"}
+		}
+
+		message = append(message, frame.payload...)
+		isFragmented = !frame.isFinal
+
+		if frame.isFinal {
+			if !utf8.Valid(message) {
+				return nil, &wsCloseError{wsInvalidData, "invalid UTF-8 text"}
+			}
+
+			return message, nil
+		}
+	}
+}
+
+func (connection *WebSocketConnection) sendPings(pingDone chan struct{}) {
+	var ticker = time.NewTicker(connection.listener.pingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if connection.write(wsPing, nil) != nil {
+				return
+			}
+
+		case <-pingDone:
+			return
+		}
+	}
+}
+
+// The messages clients send.
+type webSocketMessage struct {
+	Id          interface{}     `json:"id"`
+	Type        string          `json:"type"`
+	Path        string          `json:"path"`
+	Data        data.GenericMap `json:"data"`
+	Token       string          `json:"token"`
+	LastEventId string          `json:"lastEventId"`
+}
+
+// Handles a request message, sending back the response.
+func (connection *WebSocketConnection) handleMessage(messageData []byte) {
+	var message webSocketMessage
+
+	if err := json.Unmarshal(messageData, &message); err != nil {
+		log.Warning(webSocketLogTag, "(%s) bad message: %v", connection.Id, err)
+		connection.send(data.GenericMap{"status": requests.InvalidData.String(), "error": err.Error()})
+		return
+	}
+
+	var request = requests.NewRequest()
+
+	request.Type = requestTypeFromName(message.Type)
+	request.Path = message.Path
+	request.Metadata.MergeWith(connection.Metadata)
+	request.Metadata.Set("connectionId", connection.Id)
+	request.SetContext(connection.context)
+
+	log.Information(webSocketLogTag, "(%s) (%s) %s %s", connection.Id, request.Id, message.Type, message.Path)
+
+	var reply = data.GenericMap{"id": message.Id}
+
+	if request.Type == requests.Unknown {
+		reply.Set("status", requests.NotAllowed.String())
+		reply.Set("error", fmt.Sprintf("unknown request type: \"%s\"", message.Type))
+		connection.send(reply)
+		return
+	}
+
+	if message.Token != "" {
+		request.Metadata.Set("token", message.Token)
+	} else if token, isBearer := strings.CutPrefix(request.Metadata.GetString("header.authorization", ""), "Bearer "); isBearer {
+		request.Metadata.Set("token", token)
+	}
+
+	if (connection.listener.maxDepth > 0) && (int64(dataDepth(message.Data)) > connection.listener.maxDepth) {
+		reply.Set("status", requests.InvalidData.String())
+		reply.Set("error", fmt.Sprintf("websocket.maxJsonDepth exceeded (maximum is %d)", connection.listener.maxDepth))
+		connection.send(reply)
+		return
+	}
+
+	request.Data.MergeWith(message.Data)
+
+	var response = requests.NewResponse(request.Id)
+	var requestError = service.HandleRequest(request, response)
+
+	if request.Identity != nil {
+		connection.identity.Store(request.Identity)
+	}
+
+	if requestError != nil {
+		log.Error(webSocketLogTag, fmt.Errorf("(%s) (%s) %v", connection.Id, request.Id, requestError))
+		reply.Set("status", requests.InternalError.String())
+		reply.Set("error", requestError.Error())
+		connection.send(reply)
+		return
+	}
+
+	log.Information(webSocketLogTag, "(%s) (%s) got %s with %d data entries", connection.Id, request.Id, response.Status, len(response.Data))
+
+	if (response.Stream != nil) && (response.Status == requests.OK) {
+		request.SetContext(connection.context)
+		connection.stream(request, response, message)
+		return
+	}
+
+	reply.Set("status", response.Status.String())
+	reply.Set("data", response.Data)
+
+	if allowedTypes, hasAllowedTypes := response.Metadata.Get("allowedTypes", nil).([]requests.Type); hasAllowedTypes {
+		var typeNames = make([]string, 0, len(allowedTypes))
+
+		for _, allowedType := range allowedTypes {
+			typeNames = append(typeNames, strings.ToLower(allowedType.String()))
+		}
+
+		reply.Set("allowedTypes", typeNames)
+	}
+
+	if response.Metadata.Has("retryAfter") {
+		reply.Set("retryAfter", response.Metadata.Get("retryAfter", nil))
+	}
+
+	connection.send(reply)
+}
+
+// Answers a request message with the TooManyRequests status, without handling it.
+func (connection *WebSocketConnection) rejectMessage(messageData []byte) {
+	var message webSocketMessage
+	json.Unmarshal(messageData, &message)
+
+	log.Warning(webSocketLogTag, "(%s) too many concurrent requests (maximum is %d)", connection.Id, connection.listener.maxRequests)
+
+	connection.send(data.GenericMap{
+		"id":     message.Id,
+		"status": requests.TooManyRequests.String(),
+		"error":  fmt.Sprintf("websocket.maxConcurrentRequests exceeded (maximum is %d)", connection.listener.maxRequests),
+	})
+}
+
+// Sends the events of a streaming response, followed by an end message.
+func (connection *WebSocketConnection) stream(request *requests.Request, response *requests.Response, message webSocketMessage) {
+	var writer = &webSocketEventWriter{
+		connection:  connection,
+		requestId:   message.Id,
+		lastEventId: message.LastEventId,
+	}
+
+	var reply = data.GenericMap{"id": message.Id, "end": true}
+
+	if err := response.Stream(writer); (err != nil) && (err != requests.ErrStreamClosed) {
+		log.Error(webSocketLogTag, fmt.Errorf("(%s) (%s) stream failed: %v", connection.Id, request.Id, err))
+		reply.Set("error", err.Error())
+	}
+
+	connection.send(reply)
+}
+
+// Sends a JSON message.
+func (connection *WebSocketConnection) send(message data.GenericMap) error {
+	var messageData, err = json.Marshal(message)
+
+	if err != nil {
+		log.Error(webSocketLogTag, fmt.Errorf("(%s) %v", connection.Id, err))
+		return err
+	}
+
+	return connection.write(wsText, messageData)
+}
+
+// Writes a frame, failing with ErrStreamClosed once the connection is closed.
+func (connection *WebSocketConnection) write(opcode byte, payload []byte) error {
+	connection.writeMutex.Lock()
+	defer connection.writeMutex.Unlock()
+
+	if connection.context.Err() != nil {
+		return requests.ErrStreamClosed
+	}
+
+	connection.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+
+	if _, err := connection.conn.Write(wsFrameData(opcode, payload)); err != nil {
+		log.Verbose(webSocketLogTag, "(%s) write failed: %v", connection.Id, err)
+		connection.cancel()
+		connection.conn.Close()
+		return requests.ErrStreamClosed
+	}
+
+	return nil
+}
+
+// Sends a close frame (if the connection is still open) and closes the connection.
+func (connection *WebSocketConnection) close(code int, reason string) {
+	connection.closeOnce.Do(func() {
+		connection.write(wsClose, wsClosePayload(code, reason))
+		connection.cancel()
+		connection.conn.Close()
+	})
+}
+
+// The event writer for streaming responses sent on WebSocket connections.
+type webSocketEventWriter struct {
+	requests.EventWriter
+
+	connection  *WebSocketConnection
+	requestId   interface{}
+	lastEventId string
+}
+
+func (writer *webSocketEventWriter) Send(event *requests.Event) error {
+	var message = data.GenericMap{
+		"id":   writer.requestId,
+		"data": event.Data,
+	}
+
+	if event.Name != "" {
+		message.Set("event", event.Name)
+	}
+
+	if event.Id != "" {
+		message.Set("eventId", event.Id)
+	}
+
+	return writer.connection.send(message)
+}
+
+func (writer *webSocketEventWriter) LastEventId() string {
+	return writer.lastEventId
+}
+
+// Returns the request type with a name (ignoring case), or Unknown.
+func requestTypeFromName(name string) requests.Type {
+	for _, requestType := range []requests.Type{requests.Pull, requests.Push, requests.Update, requests.Delete, requests.Replace} {
+		if strings.EqualFold(requestType.String(), name) {
+			return requestType
+		}
+	}
+
+	return requests.Unknown
+}
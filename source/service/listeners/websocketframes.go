@@ -0,0 +1,168 @@
+package listeners
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// The WebSocket frame opcodes (RFC 6455, section 5.2).
+const (
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsBinary       = 0x2
+	wsClose        = 0x8
+	wsPing         = 0x9
+	wsPong         = 0xa
+)
+
+// The WebSocket close status codes used by the listener (RFC 6455, section 7.4.1).
+const (
+	wsNormalClosure    = 1000
+	wsGoingAway        = 1001
+	wsProtocolError    = 1002
+	wsUnsupportedData  = 1003
+	wsInvalidData      = 1007
+	wsMessageTooBig    = 1009
+	wsNoStatusReceived = 1005
+)
+
+// An error that closes a WebSocket connection with a status code.
+type wsCloseError struct {
+	code   int
+	reason string
+}
+
+func (err *wsCloseError) Error() string {
+	return fmt.Sprintf("websocket closed (%d): %s", err.code, err.reason)
+}
+
+var (
+	errWsClosed = errors.New("websocket closed")
+)
+
+const (
+	wsMaxPreallocatedPayload = 64 << 10
+)
+
+type wsFrame struct {
+	isFinal bool
+	opcode  byte
+	payload []byte
+}
+
+func (frame *wsFrame) isControl() bool {
+	return frame.opcode&0x8 != 0
+}
+
+// Reads a client frame, unmasking its payload. Frames with payloads larger than maxSize (if above 0) are rejected.
+func readWsFrame(reader *bufio.Reader, maxSize int64) (frame *wsFrame, err error) {
+	var header [2]byte
+
+	if _, err = io.ReadFull(reader, header[:]); err != nil {
+		return
+	}
+
+	frame = &wsFrame{
+		isFinal: header[0]&0x80 != 0,
+		opcode:  header[0] & 0x0f,
+	}
+
+	if header[0]&0x70 != 0 {
+		return nil, &wsCloseError{wsProtocolError, "reserved bits set"}
+	}
+
+	if header[1]&0x80 == 0 {
+		return nil, &wsCloseError{wsProtocolError, "unmasked client frame"}
+	}
+
+	var length = uint64(header[1] & 0x7f)
+
+	switch length {
+	case 126:
+		var extendedLength [2]byte
+
+		if _, err = io.ReadFull(reader, extendedLength[:]); err != nil {
+			return
+		}
+
+		length = uint64(binary.BigEndian.Uint16(extendedLength[:]))
+
+	case 127:
+		var extendedLength [8]byte
+
+		if _, err = io.ReadFull(reader, extendedLength[:]); err != nil {
+			return
+		}
+
+		if length = binary.BigEndian.Uint64(extendedLength[:]); length > math.MaxInt64 {
+			return nil, &wsCloseError{wsProtocolError, "bad frame length"}
+		}
+	}
+
+	if frame.isControl() && (!frame.isFinal || (length > 125)) {
+		return nil, &wsCloseError{wsProtocolError, "bad control frame"}
+	}
+
+	if (maxSize > 0) && (length > uint64(maxSize)) {
+		return nil, &wsCloseError{wsMessageTooBig, "message too big"}
+	}
+
+	var mask [4]byte
+
+	if _, err = io.ReadFull(reader, mask[:]); err != nil {
+		return
+	}
+
+	// The payload grows as it is read, so frame lengths (which clients choose) do not allocate memory up front
+	var payload = bytes.NewBuffer(make([]byte, 0, min(length, wsMaxPreallocatedPayload)))
+
+	if _, err = io.CopyN(payload, reader, int64(length)); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+
+		return
+	}
+
+	frame.payload = payload.Bytes()
+
+	for index := range frame.payload {
+		frame.payload[index] ^= mask[index%4]
+	}
+
+	return
+}
+
+// Builds a server (unmasked, final) frame.
+func wsFrameData(opcode byte, payload []byte) []byte {
+	var frameData = make([]byte, 0, len(payload)+10)
+
+	frameData = append(frameData, 0x80|opcode)
+
+	switch {
+	case len(payload) <= 125:
+		frameData = append(frameData, byte(len(payload)))
+	case len(payload) <= math.MaxUint16:
+		frameData = append(frameData, 126)
+		frameData = binary.BigEndian.AppendUint16(frameData, uint16(len(payload)))
+	default:
+		frameData = append(frameData, 127)
+		frameData = binary.BigEndian.AppendUint64(frameData, uint64(len(payload)))
+	}
+
+	return append(frameData, payload...)
+}
+
+// Builds a close frame payload.
+func wsClosePayload(code int, reason string) []byte {
+	if len(reason) > 123 {
+		reason = reason[:123]
+	}
+
+	return append(binary.BigEndian.AppendUint16(nil, uint16(code)), reason...)
+}
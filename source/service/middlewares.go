@@ -0,0 +1,122 @@
+package service
+
+import (
+	"errors"
+	"gogogo/log"
+	"gogogo/requests"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// The function signature for request middlewares. A middleware wraps a handler and returns the handler to be called in
+// its place.
+type Middleware func(next requests.Handler) requests.Handler
+
+// Adds global middlewares, which wrap the handlers of all routes. Global middlewares run before the route ones, in the
+// order they were added.
+func Use(middlewares ...Middleware) {
+	_middlewaresMutex.Lock()
+	defer _middlewaresMutex.Unlock()
+
+	var newMiddlewares = append(append([]Middleware{}, currentMiddlewares()...), middlewares...)
+	_middlewares.Store(&newMiddlewares)
+}
+
+// Replaces the global middlewares (including the default ones), allowing them to be reordered or removed.
+func SetMiddlewares(middlewares ...Middleware) {
+	_middlewaresMutex.Lock()
+	defer _middlewaresMutex.Unlock()
+
+	var newMiddlewares = append(make([]Middleware, 0, len(middlewares)), middlewares...)
+	_middlewares.Store(&newMiddlewares)
+}
+
+// Returns the default global middlewares: RequireAuthentication, Authorize and ValidateContract (in this order).
+func DefaultMiddlewares() []Middleware {
+	return []Middleware{RequireAuthentication, Authorize, ValidateContract}
+}
+
+// A middleware that authenticates requests with the added authenticators and rejects requests to private routes that
+// do not carry valid credentials. Requests to public routes are handled even without (or with invalid) credentials.
+func RequireAuthentication(next requests.Handler) requests.Handler {
+	return func(request *requests.Request, response *requests.Response) error {
+		var identity, err = authenticate(request)
+
+		if identity != nil {
+			log.Verbose(_logTag, "(%s) authenticated as %s", request.Id, identity.Subject)
+			request.Identity = identity
+		}
+
+		if request.Route.IsPublic {
+			return next(request, response)
+		}
+
+		if err != nil {
+			log.Verbose(_logTag, "(%s) authentication failed: %v", request.Id, err)
+
+			if errors.Is(err, ErrNotAuthorized) {
+				response.Status = requests.NotAuthorized
+			} else {
+				requireAuthentication(response)
+			}
+
+			return nil
+		}
+
+		if identity == nil {
+			log.Verbose(_logTag, "(%s) route %s:%s is not public and no credentials were specified", request.Id, strings.ToLower(request.Type.String()), request.Path)
+			requireAuthentication(response)
+			return nil
+		}
+
+		return next(request, response)
+	}
+}
+
+// A middleware that validates the request data against the route contract (if the route has one). The fields listeners
+// decoded from text (listed in the "textFields" request metadata) are converted to the contract field types first.
+func ValidateContract(next requests.Handler) requests.Handler {
+	return func(request *requests.Request, response *requests.Response) error {
+		if request.Route.Contract != nil {
+			request.Route.Contract.ConvertText(request.Data, request.Metadata.GetStrings("textFields", nil))
+
+			var contractErrors = request.Route.Contract.Validate(request.Data)
+
+			if len(contractErrors) > 0 {
+				log.Verbose(_logTag, "(%s) payload has contract validation errors: %v", request.Id, contractErrors)
+
+				response.Status = requests.InvalidData
+				response.Data["errors"] = contractErrors
+
+				return nil
+			}
+		}
+
+		return next(request, response)
+	}
+}
+
+// The global middlewares are replaced as a whole (like the route table), so requests never see a partial change.
+var (
+	_middlewares      atomic.Pointer[[]Middleware]
+	_middlewaresMutex sync.Mutex
+)
+
+func init() {
+	var defaultMiddlewares = DefaultMiddlewares()
+	_middlewares.Store(&defaultMiddlewares)
+}
+
+func currentMiddlewares() []Middleware {
+	return *_middlewares.Load()
+}
+
+// Wraps a handler with a middleware chain. The first middleware is the outermost one.
+func chainMiddlewares(handler requests.Handler, middlewares []Middleware) requests.Handler {
+	for index := len(middlewares) - 1; index >= 0; index-- {
+		handler = middlewares[index](handler)
+	}
+
+	return handler
+}
@@ -0,0 +1,258 @@
+package service
+
+import (
+	"gogogo/config"
+	"gogogo/data"
+	"gogogo/data/codecs"
+	"gogogo/data/contract"
+	"gogogo/log"
+	"gogogo/requests"
+	"net/http"
+	"strings"
+)
+
+// Generates an OpenAPI 3.1 document describing the added routes and their contracts.
+func OpenApiDocument() data.GenericMap {
+	var paths = data.NewGenericMap()
+
+	for _, route := range allRoutes() {
+		var method = openApiMethod(route.Type)
+
+		if method == "" {
+			continue
+		}
+
+		for _, pathParts := range openApiPathVariants(route.parts) {
+			var path = openApiPath(pathParts)
+			var pathItem, pathExists = paths[path].(data.GenericMap)
+
+			if !pathExists {
+				pathItem = data.NewGenericMap()
+				paths.Set(path, pathItem)
+			}
+
+			pathItem.Set(method, openApiOperation(route, pathParts))
+		}
+	}
+
+	return data.GenericMap{
+		"openapi": "3.1.0",
+		"info": data.GenericMap{
+			"title":   _name,
+			"version": _version,
+		},
+		"paths": paths,
+		"components": data.GenericMap{
+			"schemas": data.GenericMap{
+				"Data": data.GenericMap{
+					"type": "object",
+				},
+				"Error": data.GenericMap{
+					"type": "object",
+					"properties": data.GenericMap{
+						"error": data.GenericMap{"type": "string"},
+					},
+				},
+				"ValidationErrors": data.GenericMap{
+					"type": "object",
+					"properties": data.GenericMap{
+						"errors": contract.ValidationErrorsSchema(),
+					},
+				},
+			},
+			"securitySchemes": data.GenericMap{
+				"bearerAuth": data.GenericMap{
+					"type":   "http",
+					"scheme": "bearer",
+				},
+			},
+		},
+	}
+}
+
+func addOpenApiRoute() {
+	var path = config.GetString("service.openApiPath", "")
+
+	if path == "" {
+		return
+	}
+
+	log.Verbose(_logTag, "OpenAPI document path = %s", path)
+	addRoute(requests.Pull, strings.Trim(path, "/"), true, openApiHandler, nil)
+}
+
+func openApiHandler(request *requests.Request, response *requests.Response) error {
+	response.Data.MergeWith(OpenApiDocument())
+	return nil
+}
+
+func openApiMethod(requestType requests.Type) string {
+	switch requestType {
+	case requests.Pull:
+		return "get"
+	case requests.Push:
+		return "post"
+	case requests.Update:
+		return "patch"
+	case requests.Delete:
+		return "delete"
+	case requests.Replace:
+		return "put"
+	}
+
+	return ""
+}
+
+// Returns the path parts of each path a route matches: OpenAPI does not support optional path parameters, so routes
+// with optional parts are described once for each optional part that may be left out.
+func openApiPathVariants(parts []routePartInfo) [][]routePartInfo {
+	var variants = make([][]routePartInfo, 0)
+
+	for index, part := range parts {
+		if part.isOptional {
+			variants = append(variants, parts[:index])
+		}
+	}
+
+	return append(variants, parts)
+}
+
+func openApiPath(parts []routePartInfo) string {
+	var pathParts = make([]string, 0, len(parts))
+
+	for _, part := range parts {
+		if part.isVariable || part.isCatchAll {
+			pathParts = append(pathParts, "{"+part.part+"}")
+		} else {
+			pathParts = append(pathParts, part.part)
+		}
+	}
+
+	return "/" + strings.Join(pathParts, "/")
+}
+
+func openApiOperation(route *routeInfo, pathParts []routePartInfo) data.GenericMap {
+	var parameters = make([]data.GenericMap, 0)
+	var parameterNames = make([]string, 0)
+
+	for _, part := range pathParts {
+		if !part.isVariable && !part.isCatchAll {
+			continue
+		}
+
+		parameterNames = append(parameterNames, part.part)
+		parameters = append(parameters, data.GenericMap{
+			"name":     part.part,
+			"in":       "path",
+			"required": true,
+			"schema":   openApiParameterSchema(part),
+		})
+	}
+
+	var responses = data.GenericMap{
+		"200":     openApiResponse(http.StatusOK, "#/components/schemas/Data"),
+		"404":     openApiResponse(http.StatusNotFound, ""),
+		"default": openApiResponse(http.StatusInternalServerError, "#/components/schemas/Error"),
+	}
+
+	var operation = data.GenericMap{
+		"responses": responses,
+	}
+
+	if route.Type == requests.Push {
+		responses.Set("201", openApiResponse(http.StatusCreated, "#/components/schemas/Data"))
+	}
+
+	if route.timeout > 0 {
+		responses.Set("504", openApiResponse(http.StatusGatewayTimeout, ""))
+	}
+
+	if !route.IsPublic {
+		responses.Set("401", openApiResponse(http.StatusUnauthorized, ""))
+		responses.Set("403", openApiResponse(http.StatusForbidden, ""))
+		operation.Set("security", []data.GenericMap{{"bearerAuth": append(append(make([]string, 0), route.Roles...), route.Scopes...)}})
+	}
+
+	if route.Contract != nil {
+		responses.Set("422", openApiResponse(http.StatusUnprocessableEntity, "#/components/schemas/ValidationErrors"))
+
+		if (route.Type == requests.Push) || (route.Type == requests.Update) || (route.Type == requests.Replace) {
+			var content = data.NewGenericMap()
+
+			for _, mediaType := range codecs.DecoderMediaTypes() {
+				content.Set(mediaType, data.GenericMap{
+					"schema": route.Contract.Schema(parameterNames...),
+				})
+			}
+
+			operation.Set("requestBody", data.GenericMap{
+				"required": true,
+				"content":  content,
+			})
+		} else {
+			for _, field := range route.Contract.Fields() {
+				if isPathParameter(field.Name(), parameterNames) {
+					continue
+				}
+
+				parameters = append(parameters, data.GenericMap{
+					"name":     field.Name(),
+					"in":       "query",
+					"required": field.IsRequired(),
+					"schema":   field.Schema(),
+				})
+			}
+		}
+	}
+
+	if len(parameters) > 0 {
+		operation.Set("parameters", parameters)
+	}
+
+	return operation
+}
+
+func openApiParameterSchema(part routePartInfo) data.GenericMap {
+	switch part.typeName {
+	case "int":
+		return data.GenericMap{"type": "integer"}
+	case "float":
+		return data.GenericMap{"type": "number"}
+	case "bool":
+		return data.GenericMap{"type": "boolean"}
+	case "uuid":
+		return data.GenericMap{"type": "string", "format": "uuid"}
+	}
+
+	return data.GenericMap{"type": "string"}
+}
+
+func openApiResponse(status int, schemaReference string) data.GenericMap {
+	var response = data.GenericMap{
+		"description": http.StatusText(status),
+	}
+
+	if schemaReference != "" {
+		var content = data.NewGenericMap()
+
+		for _, mediaType := range codecs.EncoderMediaTypes() {
+			content.Set(mediaType, data.GenericMap{
+				"schema": data.GenericMap{"$ref": schemaReference},
+			})
+		}
+
+		response.Set("content", content)
+	}
+
+	return response
+}
+
+func isPathParameter(name string, parameterNames []string) bool {
+	for _, parameterName := range parameterNames {
+		if parameterName == name {
+			return true
+		}
+	}
+
+	return false
+}
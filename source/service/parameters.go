@@ -0,0 +1,70 @@
+package service
+
+import (
+	"regexp"
+	"strconv"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// The function signature for route parameter types. It converts a path part to the parameter value and returns false
+// if the path part is not valid for the type.
+type ParameterType func(value string) (interface{}, bool)
+
+// Registers a route parameter type, to be used in route paths as ":name<typeName>". Parameter types must be registered
+// before adding the routes that use them.
+func AddParameterType(typeName string, parameterType ParameterType) {
+	_parameterTypesMutex.Lock()
+	defer _parameterTypesMutex.Unlock()
+
+	_parameterTypes[typeName] = parameterType
+}
+
+// Returns the parameter type registered with a name, and whether there is one.
+func findParameterType(typeName string) (parameterType ParameterType, typeExists bool) {
+	_parameterTypesMutex.RLock()
+	defer _parameterTypesMutex.RUnlock()
+
+	parameterType, typeExists = _parameterTypes[typeName]
+	return
+}
+
+// Registers a route parameter type that accepts the path parts matching a regular expression (the value is kept as a
+// string).
+func AddRegexParameterType(typeName string, regex *regexp.Regexp) {
+	AddParameterType(typeName, func(value string) (interface{}, bool) {
+		return value, regex.MatchString(value)
+	})
+}
+
+var (
+	_parameterTypesMutex sync.RWMutex
+	_parameterTypes      = map[string]ParameterType{
+		"int":   intParameter,
+		"float": floatParameter,
+		"bool":  boolParameter,
+		"uuid":  uuidParameter,
+	}
+)
+
+func intParameter(value string) (interface{}, bool) {
+	var intValue, err = strconv.ParseInt(value, 10, 64)
+	return intValue, err == nil
+}
+
+func floatParameter(value string) (interface{}, bool) {
+	var floatValue, err = strconv.ParseFloat(value, 64)
+	return floatValue, err == nil
+}
+
+func boolParameter(value string) (interface{}, bool) {
+	var boolValue, err = strconv.ParseBool(value)
+	return boolValue, err == nil
+}
+
+// Only accepts the canonical (lowercase, hyphenated) form, so ids match the stored keys.
+func uuidParameter(value string) (interface{}, bool) {
+	var parsed, err = uuid.Parse(value)
+	return value, (err == nil) && (parsed.String() == value)
+}
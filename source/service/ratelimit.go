@@ -0,0 +1,189 @@
+package service
+
+import (
+	"gogogo/config"
+	"gogogo/data"
+	"gogogo/log"
+	"gogogo/requests"
+	"math"
+	"sync"
+	"time"
+)
+
+// The function signature for rate limit key functions, which tell which bucket a request is counted against.
+type RateLimitKey func(request *requests.Request) string
+
+// Counts requests by client address.
+func ByClientAddress(request *requests.Request) string {
+	return "address:" + request.Metadata.GetString("remoteAddress", "")
+}
+
+// Counts requests by authenticated subject (or by client address for unauthenticated requests).
+func BySubject(request *requests.Request) string {
+	if request.Identity != nil {
+		return "subject:" + request.Identity.Subject
+	}
+
+	return ByClientAddress(request)
+}
+
+// Counts requests by API key (or by subject for requests not authenticated with an API key).
+func ByApiKey(request *requests.Request) string {
+	if (request.Identity != nil) && request.Identity.Claims.Has("apiKey") {
+		return "apiKey:" + request.Identity.Claims.GetString("apiKey", "")
+	}
+
+	return BySubject(request)
+}
+
+// Creates a token bucket rate limit middleware: each key gets a bucket of burst requests, refilled at rate requests
+// per second. Requests exceeding the limit are rejected with the TooManyRequests status. The limit state is added to
+// the response metadata ("rateLimit" and, for rejected requests, "retryAfter").
+func RateLimit(rate float64, burst int64, key RateLimitKey) Middleware {
+	var limiter = &rateLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		key:     key,
+		buckets: make(map[string]*tokenBucket),
+	}
+
+	return limiter.middleware
+}
+
+// Adds a rate limit to the route (see RateLimit), counted separately from the global and other routes limits.
+func WithRateLimit(rate float64, burst int64, key RateLimitKey) RouteOption {
+	return WithMiddleware(RateLimit(rate, burst, key))
+}
+
+type tokenBucket struct {
+	tokens     float64
+	lastRefill time.Time
+}
+
+type rateLimiter struct {
+	rate        float64
+	burst       float64
+	key         RateLimitKey
+	mutex       sync.Mutex
+	buckets     map[string]*tokenBucket
+	lastCleanup time.Time
+}
+
+func (limiter *rateLimiter) middleware(next requests.Handler) requests.Handler {
+	return func(request *requests.Request, response *requests.Response) error {
+		var key = limiter.key(request)
+		var isAllowed, remaining, retryAfter, reset = limiter.take(key)
+
+		response.Metadata.Set("rateLimit", data.GenericMap{
+			"limit":     int64(limiter.burst),
+			"remaining": remaining,
+			"reset":     seconds(reset),
+		})
+
+		if !isAllowed {
+			log.Warning(_logTag, "(%s) rate limit exceeded for %s", request.Id, key)
+			response.Status = requests.TooManyRequests
+			response.Metadata.Set("retryAfter", seconds(retryAfter))
+			return nil
+		}
+
+		return next(request, response)
+	}
+}
+
+// Takes a token from the key bucket, returning whether there was one, the remaining tokens, the time until the next
+// token and the time until the bucket is full again.
+func (limiter *rateLimiter) take(key string) (isAllowed bool, remaining int64, retryAfter time.Duration, reset time.Duration) {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	var now = time.Now()
+	limiter.cleanup(now)
+
+	var bucket, bucketExists = limiter.buckets[key]
+
+	if !bucketExists {
+		bucket = &tokenBucket{
+			tokens:     limiter.burst,
+			lastRefill: now,
+		}
+
+		limiter.buckets[key] = bucket
+	}
+
+	bucket.tokens = math.Min(limiter.burst, bucket.tokens+now.Sub(bucket.lastRefill).Seconds()*limiter.rate)
+	bucket.lastRefill = now
+
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		isAllowed = true
+	} else {
+		retryAfter = limiter.refillTime(1 - bucket.tokens)
+	}
+
+	return isAllowed, int64(bucket.tokens), retryAfter, limiter.refillTime(limiter.burst - bucket.tokens)
+}
+
+func (limiter *rateLimiter) refillTime(tokens float64) time.Duration {
+	if limiter.rate <= 0 {
+		return 0
+	}
+
+	return time.Duration(tokens / limiter.rate * float64(time.Second))
+}
+
+// Removes the buckets that are full again (at most once per minute).
+func (limiter *rateLimiter) cleanup(now time.Time) {
+	if now.Sub(limiter.lastCleanup) < time.Minute {
+		return
+	}
+
+	limiter.lastCleanup = now
+
+	for key, bucket := range limiter.buckets {
+		if bucket.tokens+now.Sub(bucket.lastRefill).Seconds()*limiter.rate >= limiter.burst {
+			delete(limiter.buckets, key)
+		}
+	}
+}
+
+// Adds the global rate limit if one is configured ("rateLimit.rate" requests per second, with bursts of
+// "rateLimit.burst" requests, counted by "rateLimit.key": "address", "subject" or "apiKey"). Limits counted by address
+// apply to every request, before the route lookup and the middlewares, while the other ones need the request identity
+// and so only count the requests that pass authentication.
+func addConfigRateLimit() {
+	var rate = config.GetFloat("rateLimit.rate", 0)
+
+	if rate <= 0 {
+		return
+	}
+
+	var burst = config.GetInt("rateLimit.burst", int64(math.Ceil(rate)))
+	var keyName = config.GetString("rateLimit.key", "address")
+	var key RateLimitKey
+
+	switch keyName {
+	case "subject":
+		key = BySubject
+	case "apiKey", "apikey":
+		key = ByApiKey
+	default:
+		key = ByClientAddress
+	}
+
+	log.Verbose(_logTag, "rate limit = %v requests per second (burst: %d, key: %s)", rate, burst, keyName)
+
+	if keyName == "subject" || keyName == "apiKey" || keyName == "apikey" {
+		Use(RateLimit(rate, burst, key))
+	} else {
+		_requestRateLimit = RateLimit(rate, burst, key)
+	}
+}
+
+var (
+	_requestRateLimit Middleware
+)
+
+func seconds(duration time.Duration) int64 {
+	return int64(math.Ceil(duration.Seconds()))
+}
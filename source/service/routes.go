@@ -7,60 +7,121 @@ import (
 	"gogogo/log"
 	"gogogo/requests"
 	"strings"
+	"time"
 )
 
-func AddPublicPull(path string, handler requests.Handler, contract *contract.Contract) {
-	addRoute(requests.Pull, path, true, handler, contract)
+func AddPublicPull(path string, handler requests.Handler, contract *contract.Contract, options ...RouteOption) {
+	addRoute(requests.Pull, path, true, handler, contract, options...)
 }
 
-func AddPublicPush(path string, handler requests.Handler, contract *contract.Contract) {
-	addRoute(requests.Push, path, true, handler, contract)
+func AddPublicPush(path string, handler requests.Handler, contract *contract.Contract, options ...RouteOption) {
+	addRoute(requests.Push, path, true, handler, contract, options...)
+}
 
+func AddPublicUpdate(path string, handler requests.Handler, contract *contract.Contract, options ...RouteOption) {
+	addRoute(requests.Update, path, true, handler, contract, options...)
 }
 
-func AddPublicUpdate(path string, handler requests.Handler, contract *contract.Contract) {
-	addRoute(requests.Update, path, true, handler, contract)
+func AddPublicDelete(path string, handler requests.Handler, contract *contract.Contract, options ...RouteOption) {
+	addRoute(requests.Delete, path, true, handler, contract, options...)
+}
 
+func AddPublicReplace(path string, handler requests.Handler, contract *contract.Contract, options ...RouteOption) {
+	addRoute(requests.Replace, path, true, handler, contract, options...)
 }
 
-func AddPublicDelete(path string, handler requests.Handler, contract *contract.Contract) {
-	addRoute(requests.Delete, path, true, handler, contract)
+func AddPrivatePull(path string, handler requests.Handler, contract *contract.Contract, options ...RouteOption) {
+	addRoute(requests.Pull, path, false, handler, contract, options...)
+}
 
+func AddPrivatePush(path string, handler requests.Handler, contract *contract.Contract, options ...RouteOption) {
+	addRoute(requests.Push, path, false, handler, contract, options...)
 }
 
-func AddPrivatePull(path string, handler requests.Handler, contract *contract.Contract) {
-	addRoute(requests.Pull, path, false, handler, contract)
+func AddPrivateUpdate(path string, handler requests.Handler, contract *contract.Contract, options ...RouteOption) {
+	addRoute(requests.Update, path, false, handler, contract, options...)
+}
 
+func AddPrivateDelete(path string, handler requests.Handler, contract *contract.Contract, options ...RouteOption) {
+	addRoute(requests.Delete, path, false, handler, contract, options...)
 }
 
-func AddPrivatePush(path string, handler requests.Handler, contract *contract.Contract) {
-	addRoute(requests.Push, path, false, handler, contract)
+func AddPrivateReplace(path string, handler requests.Handler, contract *contract.Contract, options ...RouteOption) {
+	addRoute(requests.Replace, path, false, handler, contract, options...)
 }
 
-func AddPrivateUpdate(path string, handler requests.Handler, contract *contract.Contract) {
-	addRoute(requests.Update, path, false, handler, contract)
+// Returns the route matching the request type and path (or nil if there is none).
+func FindRoute(requestType requests.Type, path string) *requests.Route {
+	if route := currentRouteTable().find(requestType, path); route != nil {
+		return &route.Route
+	}
+
+	return nil
 }
 
-func AddPrivateDelete(path string, handler requests.Handler, contract *contract.Contract) {
-	addRoute(requests.Delete, path, false, handler, contract)
+// Returns the request types that have a route matching the path, sorted by type.
+func AllowedTypes(path string) []requests.Type {
+	return currentRouteTable().allowedTypes(path)
 }
 
 type routePartInfo struct {
-	isVariable bool
-	part       string
+	isVariable    bool
+	isCatchAll    bool
+	isOptional    bool
+	part          string
+	typeName      string
+	parameterType ParameterType
 }
 
 type routeInfo struct {
-	requestType requests.Type
-	isPublic    bool
+	requests.Route
+
 	handler     requests.Handler
 	parts       []routePartInfo
-	contract    *contract.Contract
+	middlewares []Middleware
+	timeout     time.Duration
 }
 
-var (
-	_routes = make([]*routeInfo, 0)
-)
+// The function signature for route options, which change how a route is handled.
+type RouteOption func(route *routeInfo)
+
+// Adds middlewares to the chain that wraps the route handler. Route middlewares run after the global ones, in the
+// order they were added.
+func WithMiddleware(middlewares ...Middleware) RouteOption {
+	return func(route *routeInfo) {
+		route.middlewares = append(route.middlewares, middlewares...)
+	}
+}
+
+// Restricts the route to identities having at least one of the specified roles.
+func RequireRoles(roles ...string) RouteOption {
+	return func(route *routeInfo) {
+		route.Roles = append(route.Roles, roles...)
+	}
+}
+
+// Restricts the route to identities that were granted all the specified scopes.
+func RequireScopes(scopes ...string) RouteOption {
+	return func(route *routeInfo) {
+		route.Scopes = append(route.Scopes, scopes...)
+	}
+}
+
+// Overrides a configuration parameter for the route. Listeners look up route settings before the configuration, for
+// the parameters that can be set per route.
+func WithSetting(paramName string, value interface{}) RouteOption {
+	return func(route *routeInfo) {
+		route.Settings.Set(strings.ToLower(paramName), value)
+	}
+}
+
+// Sets the maximum time the route handler has to handle a request. The request context is canceled once the timeout
+// expires and the request is answered with the Timeout status.
+func WithTimeout(timeout time.Duration) RouteOption {
+	return func(route *routeInfo) {
+		route.timeout = timeout
+	}
+}
 
 func breakPath(path string) []string {
 	var pathParts = strings.Split(path, "/")
@@ -72,85 +133,148 @@ func breakPath(path string) []string {
 	return pathParts
 }
 
-func findRoute(requestType requests.Type, path string) *routeInfo {
-	var pathParts = breakPath(path)
+// Returns all the added routes, sorted by path and request type.
+func allRoutes() []*routeInfo {
+	return currentRouteTable().sortedRoutes()
+}
 
-	if len(pathParts) == 0 {
-		return nil
-	}
+// Adds a route. Routes can be added (and removed) safely while the service is running: the change takes effect
+// atomically and does not block the requests being handled.
+func AddRoute(requestType requests.Type, path string, isPublic bool, handler requests.Handler, contract *contract.Contract, options ...RouteOption) error {
+	log.Verbose(_logTag, "adding route for %s:%s", strings.ToLower(requestType.String()), path)
 
-	for _, route := range _routes {
-		if (route.requestType != requestType) || (len(route.parts) != len(pathParts)) {
-			continue
-		}
+	var routeParts, parseError = parseRoutePath(path)
 
-		var routeFound = true
+	if parseError != nil {
+		return fmt.Errorf("bad route path %s:%s: %v", strings.ToLower(requestType.String()), path, parseError)
+	}
 
-		for index, routePart := range route.parts {
-			if routePart.isVariable {
-				continue
-			}
+	log.Verbose(_logTag, "route parts: %v", routeParts)
 
-			if routePart.part != pathParts[index] {
-				routeFound = false
-				break
-			}
-		}
+	var newRoute = &routeInfo{
+		Route: requests.Route{
+			Type:     requestType,
+			Path:     path,
+			IsPublic: isPublic,
+			Contract: contract,
+			Settings: data.NewGenericMap(),
+		},
+		handler:     handler,
+		parts:       routeParts,
+		middlewares: make([]Middleware, 0),
+	}
 
-		if routeFound {
-			return route
-		}
+	for _, option := range options {
+		option(newRoute)
+	}
+
+	if err := storeRoute(newRoute); err != nil {
+		return err
 	}
 
+	log.Information(_logTag, "added route for %s:%s [public: %v]", strings.ToLower(requestType.String()), path, isPublic)
 	return nil
 }
 
-func addRoute(requestType requests.Type, path string, isPublic bool, handler requests.Handler, contract *contract.Contract) {
-	log.Verbose(_logTag, "adding route for %s:%s", strings.ToLower(requestType.String()), path)
+// Removes the route with the specified request type and path (as it was added).
+func RemoveRoute(requestType requests.Type, path string) error {
+	if err := deleteRoute(requestType, path); err != nil {
+		return err
+	}
 
-	if existingRoute := findRoute(requestType, path); existingRoute != nil {
-		log.Error(_logTag, fmt.Errorf("route for %s:%s already exsits", strings.ToLower(requestType.String()), path))
-		return
+	log.Information(_logTag, "removed route for %s:%s", strings.ToLower(requestType.String()), path)
+	return nil
+}
+
+func addRoute(requestType requests.Type, path string, isPublic bool, handler requests.Handler, contract *contract.Contract, options ...RouteOption) {
+	if err := AddRoute(requestType, path, isPublic, handler, contract, options...); err != nil {
+		log.Error(_logTag, err)
 	}
+}
 
+// Breaks a route path into its parts. Variable parts are prefixed with ":" and catch-all parts (which capture the
+// remainder of the path) with "*". Variable parts may be constrained to a parameter type with a "<typeName>" suffix.
+// Variable and catch-all parts may be marked as optional with a "?" suffix, as long as all the parts after them are
+// optional too.
+func parseRoutePath(path string) (routeParts []routePartInfo, err error) {
 	var pathParts = strings.Split(path, "/")
-	var routeParts = make([]routePartInfo, 0)
 
-	for _, part := range pathParts {
-		var isVariable = strings.HasPrefix(part, ":")
+	routeParts = make([]routePartInfo, 0, len(pathParts))
 
-		if isVariable {
-			part = part[1:]
+	for index, part := range pathParts {
+		var partInfo = routePartInfo{
+			isVariable: strings.HasPrefix(part, ":"),
+			isCatchAll: strings.HasPrefix(part, "*"),
+			part:       part,
 		}
 
-		routeParts = append(routeParts, routePartInfo{
-			isVariable, part,
-		})
-	}
+		if partInfo.isVariable || partInfo.isCatchAll {
+			partInfo.part = part[1:]
 
-	log.Verbose(_logTag, "route parts: %v", routeParts)
+			if strings.HasSuffix(partInfo.part, "?") {
+				partInfo.isOptional = true
+				partInfo.part = partInfo.part[:len(partInfo.part)-1]
+			}
 
-	_routes = append(_routes, &routeInfo{
-		requestType: requestType,
-		isPublic:    isPublic,
-		handler:     handler,
-		parts:       routeParts,
-		contract:    contract,
-	})
+			if typeStart := strings.Index(partInfo.part, "<"); (typeStart >= 0) && strings.HasSuffix(partInfo.part, ">") {
+				partInfo.typeName = partInfo.part[typeStart+1 : len(partInfo.part)-1]
+				partInfo.part = partInfo.part[:typeStart]
 
-	log.Information(_logTag, "added route for %s:%s [public: %v]", strings.ToLower(requestType.String()), path, isPublic)
+				if partInfo.isCatchAll {
+					return nil, fmt.Errorf("catch-all part can not be typed: %s", part)
+				}
+
+				var typeExists bool
+
+				if partInfo.parameterType, typeExists = findParameterType(partInfo.typeName); !typeExists {
+					return nil, fmt.Errorf("unknown parameter type: %s", partInfo.typeName)
+				}
+			}
+
+			if partInfo.part == "" {
+				return nil, fmt.Errorf("unnamed path part: %s", part)
+			}
+		}
+
+		if partInfo.isCatchAll && (index != len(pathParts)-1) {
+			return nil, fmt.Errorf("catch-all part must be the last one: %s", part)
+		}
+
+		if !partInfo.isOptional && (index > 0) && routeParts[index-1].isOptional {
+			return nil, fmt.Errorf("required part after an optional one: %s", part)
+		}
+
+		routeParts = append(routeParts, partInfo)
+	}
+
+	return
 }
 
 func extractRouteData(route *routeInfo, path string) (routeData data.GenericMap) {
-	var pathParts = strings.Split(path, "/")[1:]
+	var pathParts = breakPath(path)
 
 	routeData = data.NewGenericMap()
 
 	for index, part := range route.parts {
+		if index >= len(pathParts) {
+			break
+		}
+
+		if part.isCatchAll {
+			routeData.Set(part.part, strings.Join(pathParts[index:], "/"))
+			break
+		}
+
 		if !part.isVariable {
 			continue
 		}
 
+		if part.parameterType != nil {
+			var typedValue, _ = part.parameterType(pathParts[index])
+			routeData.Set(part.part, typedValue)
+			continue
+		}
+
 		routeData.Set(part.part, pathParts[index])
 	}
 
@@ -1,6 +1,8 @@
 package service
 
 import (
+	"context"
+	"fmt"
 	"gogogo/log"
 	"gogogo/requests"
 	"os"
@@ -18,6 +20,8 @@ type Listener interface {
 // Initializes the service infrastructure.
 func Start(name string, versionString string, listeners ...Listener) {
 	log.Information(_logTag, "%s - version %s", name, versionString)
+	_name = name
+	_version = versionString
 	_listeners = listeners
 }
 
@@ -25,6 +29,9 @@ func Start(name string, versionString string, listeners ...Listener) {
 func Run() (err error) {
 	defer log.Information(_logTag, "stopped")
 
+	addOpenApiRoute()
+	addConfigRateLimit()
+
 	for _, listener := range _listeners {
 		if err = listener.Start(); err != nil {
 			stopListeners()
@@ -57,41 +64,48 @@ func Stop() {
 func HandleRequest(request *requests.Request, response *requests.Response) error {
 	log.Information(_logTag, "(%s) %s:%s", request.Id, strings.ToLower(request.Type.String()), request.Path)
 
-	var route = findRoute(request.Type, request.Path)
+	if _requestRateLimit != nil {
+		return _requestRateLimit(handleRoute)(request, response)
+	}
+
+	return handleRoute(request, response)
+}
+
+// Looks up the request route and handles the request with it.
+func handleRoute(request *requests.Request, response *requests.Response) error {
+	var table = currentRouteTable()
+	var route = table.find(request.Type, request.Path)
 
 	if route == nil {
+		if allowedTypes := table.allowedTypes(request.Path); len(allowedTypes) > 0 {
+			log.Warning(_logTag, "(%s) %s not allowed for %s (allowed: %v)", request.Id, strings.ToLower(request.Type.String()), request.Path, allowedTypes)
+			response.Status = requests.NotAllowed
+			response.Metadata.Set("allowedTypes", allowedTypes)
+			return nil
+		}
+
 		log.Warning(_logTag, "(%s) route not found for %s:%s", request.Id, strings.ToLower(request.Type.String()), request.Path)
 		response.Status = requests.ResourceNotFound
 		return nil
 	}
 
+	request.Route = &route.Route
 	request.Data.MergeWith(extractRouteData(route, request.Path))
 
-	if !route.isPublic && (request.Metadata.GetString("Token", "") == "") {
-		log.Verbose(_logTag, "(%s) route %s:%s is not public and no authorization token was specified", request.Id, strings.ToLower(request.Type.String()), request.Path)
-		response.Status = requests.AuthenticationRequired
-		return nil
-	}
-
-	if route.contract != nil {
-		var contractErrors = route.contract.Validate(request.Data)
-
-		if len(contractErrors) > 0 {
-			log.Verbose(_logTag, "(%s) payload has contract validation errors: %v", request.Id, contractErrors)
+	log.Verbose(_logTag, "(%s) handling request", request.Id)
 
-			response.Status = requests.InvalidData
-			response.Data["errors"] = contractErrors
+	var handler = chainMiddlewares(chainMiddlewares(route.handler, route.middlewares), currentMiddlewares())
 
-			return nil
-		}
+	if route.timeout > 0 {
+		return handleWithTimeout(handler, route.timeout, request, response)
 	}
 
-	log.Verbose(_logTag, "(%s) handling request", request.Id)
-
-	return route.handler(request, response)
+	return handler(request, response)
 }
 
 var (
+	_name          string
+	_version       string
 	_listeners     []Listener
 	_isRunning     bool
 	_signalChannel chan os.Signal
@@ -101,6 +115,58 @@ const (
 	_logTag = "service"
 )
 
+// Runs a handler with a deadline. The handler gets its own copy of the request and its own response, which are copied
+// back only if the handler finishes in time (as it may keep running after the deadline, in which case it removes the
+// request files once it is done).
+func handleWithTimeout(handler requests.Handler, timeout time.Duration, request *requests.Request, response *requests.Response) error {
+	var requestContext, cancel = context.WithTimeout(request.Context(), timeout)
+	defer cancel()
+
+	var handlerRequest = request.Clone()
+	handlerRequest.SetContext(requestContext)
+
+	var handlerResponse = requests.NewResponse(request.Id)
+	var handlerDone = make(chan error, 1)
+
+	go func() {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				handlerDone <- fmt.Errorf("handler panic: %v", recovered)
+			}
+		}()
+
+		handlerDone <- handler(handlerRequest, handlerResponse)
+	}()
+
+	select {
+	case err := <-handlerDone:
+		*request = *handlerRequest
+		*response = *handlerResponse
+		return err
+
+	case <-requestContext.Done():
+		// The files are still used by the handler, which removes them once it is done
+		for name := range request.Data {
+			if len(request.Files(name)) > 0 {
+				request.Data.Unset(name)
+			}
+		}
+
+		go func() {
+			<-handlerDone
+			handlerRequest.RemoveFiles()
+		}()
+
+		if requestContext.Err() != context.DeadlineExceeded {
+			return requestContext.Err()
+		}
+
+		log.Warning(_logTag, "(%s) request timed out after %v", request.Id, timeout)
+		response.Status = requests.Timeout
+		return nil
+	}
+}
+
 func waitSignal() {
 	_signalChannel = make(chan os.Signal, 1)
 	signal.Notify(_signalChannel, os.Interrupt)
@@ -0,0 +1,172 @@
+package service
+
+import (
+	"fmt"
+	"gogogo/requests"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// An immutable snapshot of the added routes. Changes to the routes create a new table, which replaces the current one
+// atomically, so route lookups never block (or see a partially changed table).
+type routeTable struct {
+	routes []*routeInfo
+	trees  map[requests.Type]*routeNode
+}
+
+// Creates a route table with the specified routes (which must not conflict with each other).
+func newRouteTable(routes []*routeInfo) *routeTable {
+	var table = &routeTable{
+		routes: routes,
+		trees:  make(map[requests.Type]*routeNode),
+	}
+
+	for _, route := range routes {
+		table.tree(route.Type).insert(route.parts, route)
+	}
+
+	return table
+}
+
+func (table *routeTable) tree(requestType requests.Type) *routeNode {
+	var routeTree, treeExists = table.trees[requestType]
+
+	if !treeExists {
+		routeTree = newRouteNode()
+		table.trees[requestType] = routeTree
+	}
+
+	return routeTree
+}
+
+func (table *routeTable) find(requestType requests.Type, path string) *routeInfo {
+	var pathParts = breakPath(path)
+
+	if len(pathParts) == 0 {
+		return nil
+	}
+
+	var routeTree, treeExists = table.trees[requestType]
+
+	if !treeExists {
+		return nil
+	}
+
+	return routeTree.find(pathParts)
+}
+
+// Returns the request types that have a route matching the path, sorted by type.
+func (table *routeTable) allowedTypes(path string) []requests.Type {
+	var allowedTypes = make([]requests.Type, 0)
+
+	for requestType := range table.trees {
+		if table.find(requestType, path) != nil {
+			allowedTypes = append(allowedTypes, requestType)
+		}
+	}
+
+	sort.Slice(allowedTypes, func(i, j int) bool {
+		return allowedTypes[i] < allowedTypes[j]
+	})
+
+	return allowedTypes
+}
+
+// Returns a new table with the route added, or the route it conflicts with. Only the tree nodes along the route path
+// are copied, the other ones are shared with the current table.
+func (table *routeTable) with(newRoute *routeInfo) (*routeTable, *routeInfo) {
+	var newTable = &routeTable{
+		routes: append(make([]*routeInfo, 0, len(table.routes)+1), table.routes...),
+		trees:  make(map[requests.Type]*routeNode, len(table.trees)+1),
+	}
+
+	for requestType, routeTree := range table.trees {
+		newTable.trees[requestType] = routeTree
+	}
+
+	if routeTree, treeExists := table.trees[newRoute.Type]; treeExists {
+		newTable.trees[newRoute.Type] = routeTree.copyPath(newRoute.parts)
+	}
+
+	if conflictingRoute := newTable.tree(newRoute.Type).insert(newRoute.parts, newRoute); conflictingRoute != nil {
+		return nil, conflictingRoute
+	}
+
+	newTable.routes = append(newTable.routes, newRoute)
+	return newTable, nil
+}
+
+// Returns a new table without the route with the specified type and path, or nil if there is no such route.
+func (table *routeTable) without(requestType requests.Type, path string) *routeTable {
+	var routes = make([]*routeInfo, 0, len(table.routes))
+
+	for _, route := range table.routes {
+		if (route.Type != requestType) || (route.Path != path) {
+			routes = append(routes, route)
+		}
+	}
+
+	if len(routes) == len(table.routes) {
+		return nil
+	}
+
+	return newRouteTable(routes)
+}
+
+// Returns all the routes in the table, sorted by path and request type.
+func (table *routeTable) sortedRoutes() []*routeInfo {
+	var routes = append(make([]*routeInfo, 0, len(table.routes)), table.routes...)
+
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+
+		return routes[i].Type < routes[j].Type
+	})
+
+	return routes
+}
+
+var (
+	_routeTable      atomic.Pointer[routeTable]
+	_routeTableMutex sync.Mutex
+)
+
+func init() {
+	_routeTable.Store(newRouteTable(make([]*routeInfo, 0)))
+}
+
+func currentRouteTable() *routeTable {
+	return _routeTable.Load()
+}
+
+func storeRoute(newRoute *routeInfo) error {
+	_routeTableMutex.Lock()
+	defer _routeTableMutex.Unlock()
+
+	var newTable, conflictingRoute = currentRouteTable().with(newRoute)
+
+	if conflictingRoute != nil {
+		return fmt.Errorf("route for %s:%s conflicts with %s:%s", strings.ToLower(newRoute.Type.String()), newRoute.Path, strings.ToLower(conflictingRoute.Type.String()), conflictingRoute.Path)
+	}
+
+	_routeTable.Store(newTable)
+	return nil
+}
+
+func deleteRoute(requestType requests.Type, path string) error {
+	_routeTableMutex.Lock()
+	defer _routeTableMutex.Unlock()
+
+	var newTable = currentRouteTable().without(requestType, path)
+
+	if newTable == nil {
+		return fmt.Errorf("route for %s:%s not found", strings.ToLower(requestType.String()), path)
+	}
+
+	_routeTable.Store(newTable)
+	return nil
+}
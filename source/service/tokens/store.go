@@ -0,0 +1,135 @@
+package tokens
+
+import (
+	"sync"
+	"time"
+)
+
+// A refresh token entry (only its SHA-256 hash is stored). Rotated tokens keep the family of the token they replace.
+type RefreshToken struct {
+	Hash      string
+	FamilyId  string
+	Subject   string
+	Roles     []string
+	Scopes    []string
+	ExpiresAt time.Time
+}
+
+// Stores refresh tokens and the revocation list (of access token ids and rotated refresh token hashes).
+type Store interface {
+	SaveRefreshToken(token *RefreshToken) error
+	RefreshToken(tokenHash string) (*RefreshToken, error)
+	DeleteRefreshTokens(familyId string) error
+	Revoke(id string, expiresAt time.Time) error
+	IsRevoked(id string) (bool, error)
+	Consume(tokenHash string, expiresAt time.Time) (bool, error)
+}
+
+// An in-memory token store. Expired entries are removed as the store is used.
+type MemoryTokenStore struct {
+	Store
+
+	mutex         sync.Mutex
+	refreshTokens map[string]*RefreshToken
+	revokedIds    map[string]time.Time
+	lastCleanup   time.Time
+}
+
+// Creates a new, empty in-memory token store.
+func MemoryStore() *MemoryTokenStore {
+	return &MemoryTokenStore{
+		refreshTokens: make(map[string]*RefreshToken),
+		revokedIds:    make(map[string]time.Time),
+		lastCleanup:   time.Now(),
+	}
+}
+
+func (store *MemoryTokenStore) SaveRefreshToken(token *RefreshToken) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	store.cleanup()
+	store.refreshTokens[token.Hash] = token
+
+	return nil
+}
+
+func (store *MemoryTokenStore) RefreshToken(tokenHash string) (*RefreshToken, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if token, tokenExists := store.refreshTokens[tokenHash]; tokenExists && time.Now().Before(token.ExpiresAt) {
+		return token, nil
+	}
+
+	return nil, nil
+}
+
+func (store *MemoryTokenStore) DeleteRefreshTokens(familyId string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	for tokenHash, token := range store.refreshTokens {
+		if token.FamilyId == familyId {
+			delete(store.refreshTokens, tokenHash)
+		}
+	}
+
+	return nil
+}
+
+func (store *MemoryTokenStore) Revoke(id string, expiresAt time.Time) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	store.cleanup()
+	store.revokedIds[id] = expiresAt
+
+	return nil
+}
+
+func (store *MemoryTokenStore) IsRevoked(id string) (bool, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	var _, isRevoked = store.revokedIds[id]
+	return isRevoked, nil
+}
+
+// Atomically revokes a refresh token hash, returning false if it was already revoked.
+func (store *MemoryTokenStore) Consume(tokenHash string, expiresAt time.Time) (bool, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if _, isRevoked := store.revokedIds[tokenHash]; isRevoked {
+		return false, nil
+	}
+
+	store.cleanup()
+	store.revokedIds[tokenHash] = expiresAt
+
+	return true, nil
+}
+
+// Removes the expired entries (at most once per minute).
+func (store *MemoryTokenStore) cleanup() {
+	var now = time.Now()
+
+	if now.Sub(store.lastCleanup) < time.Minute {
+		return
+	}
+
+	store.lastCleanup = now
+
+	for tokenHash, token := range store.refreshTokens {
+		if now.After(token.ExpiresAt) {
+			delete(store.refreshTokens, tokenHash)
+		}
+	}
+
+	for id, expiresAt := range store.revokedIds {
+		if now.After(expiresAt) {
+			delete(store.revokedIds, id)
+		}
+	}
+}
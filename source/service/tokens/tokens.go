@@ -0,0 +1,221 @@
+package tokens
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"gogogo/config"
+	"gogogo/data"
+	"gogogo/data/contract"
+	"gogogo/log"
+	"gogogo/requests"
+	"gogogo/service"
+	"gogogo/service/authenticators"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Issues HS256 access tokens (signed with auth.jwt.secret) and rotating refresh tokens.
+type Issuer struct {
+	authenticator   service.Authenticator
+	store           Store
+	secret          []byte
+	issuer          string
+	audience        string
+	rolesClaim      string
+	accessTokenTtl  time.Duration
+	refreshTokenTtl time.Duration
+}
+
+// Adds the "login", "refresh" and "revoke" token routes under the specified path prefix (failing if there is no
+// auth.jwt.secret to sign tokens with). The returned issuer can be used as the JWT authenticator revocation list.
+func AddRoutes(prefix string, authenticator service.Authenticator, store Store) (issuer *Issuer, err error) {
+	issuer = &Issuer{
+		authenticator:   authenticator,
+		store:           store,
+		secret:          []byte(config.GetString("auth.jwt.secret", "")),
+		issuer:          config.GetString("auth.jwt.issuer", ""),
+		audience:        config.GetString("auth.jwt.audience", ""),
+		rolesClaim:      config.GetString("auth.jwt.rolesClaim", "roles"),
+		accessTokenTtl:  time.Duration(config.GetInt("auth.tokens.accessTokenTtl", 900)) * time.Second,
+		refreshTokenTtl: time.Duration(config.GetInt("auth.tokens.refreshTokenTtl", 30*24*3600)) * time.Second,
+	}
+
+	if len(issuer.secret) == 0 {
+		err = errors.New("no secret to sign access tokens (auth.jwt.secret)")
+		log.Error(tokensLogTag, err)
+		return nil, err
+	}
+
+	log.Verbose(tokensLogTag, "access token TTL = %v", issuer.accessTokenTtl)
+	log.Verbose(tokensLogTag, "refresh token TTL = %v", issuer.refreshTokenTtl)
+
+	var group = service.Group(prefix).Public()
+
+	group.AddPush("login", issuer.login, nil)
+	group.AddPush("refresh", issuer.refresh, refreshContract)
+	group.AddPush("revoke", issuer.revoke, revokeContract)
+
+	return
+}
+
+func (issuer *Issuer) IsRevoked(tokenId string) (bool, error) {
+	return issuer.store.IsRevoked(tokenId)
+}
+
+var refreshContract = contract.New(
+	contract.String("refreshToken").Required(),
+)
+
+var revokeContract = contract.New(
+	contract.String("refreshToken").Required(),
+)
+
+func (issuer *Issuer) login(request *requests.Request, response *requests.Response) error {
+	var identity, err = issuer.authenticator.Authenticate(request)
+
+	if (identity == nil) || (err != nil) {
+		log.Verbose(tokensLogTag, "(%s) login failed: %v", request.Id, err)
+		response.Status = requests.AuthenticationRequired
+		return nil
+	}
+
+	log.Information(tokensLogTag, "(%s) issuing tokens for %s", request.Id, identity.Subject)
+
+	return issuer.issue(&RefreshToken{
+		FamilyId: uuid.NewString(),
+		Subject:  identity.Subject,
+		Roles:    identity.Roles,
+		Scopes:   identity.Scopes,
+	}, response)
+}
+
+func (issuer *Issuer) refresh(request *requests.Request, response *requests.Response) error {
+	var tokenHash = hashToken(request.Data.GetString("refreshToken", ""))
+	var refreshToken, err = issuer.store.RefreshToken(tokenHash)
+
+	if err != nil {
+		return err
+	}
+
+	if refreshToken == nil {
+		log.Verbose(tokensLogTag, "(%s) unknown refresh token", request.Id)
+		response.Status = requests.AuthenticationRequired
+		return nil
+	}
+
+	var isConsumed bool
+
+	if isConsumed, err = issuer.store.Consume(tokenHash, refreshToken.ExpiresAt); err != nil {
+		return err
+	}
+
+	if !isConsumed {
+		log.Warning(tokensLogTag, "(%s) reused refresh token for %s, revoking its family", request.Id, refreshToken.Subject)
+		response.Status = requests.AuthenticationRequired
+		return issuer.store.DeleteRefreshTokens(refreshToken.FamilyId)
+	}
+
+	log.Information(tokensLogTag, "(%s) refreshing tokens for %s", request.Id, refreshToken.Subject)
+
+	return issuer.issue(&RefreshToken{
+		FamilyId: refreshToken.FamilyId,
+		Subject:  refreshToken.Subject,
+		Roles:    refreshToken.Roles,
+		Scopes:   refreshToken.Scopes,
+	}, response)
+}
+
+func (issuer *Issuer) revoke(request *requests.Request, response *requests.Response) error {
+	var refreshToken, err = issuer.store.RefreshToken(hashToken(request.Data.GetString("refreshToken", "")))
+
+	if err != nil {
+		return err
+	}
+
+	if refreshToken != nil {
+		log.Information(tokensLogTag, "(%s) revoking refresh tokens for %s", request.Id, refreshToken.Subject)
+
+		if err = issuer.store.DeleteRefreshTokens(refreshToken.FamilyId); err != nil {
+			return err
+		}
+	}
+
+	if (request.Identity != nil) && request.Identity.Claims.Has("jti") {
+		log.Information(tokensLogTag, "(%s) revoking access token for %s", request.Id, request.Identity.Subject)
+
+		var expiresAt = time.Unix(request.Identity.Claims.GetInt("exp", 0), 0)
+
+		if err = issuer.store.Revoke(request.Identity.Claims.GetString("jti", ""), expiresAt); err != nil {
+			return err
+		}
+	}
+
+	response.Status = requests.OK
+	return nil
+}
+
+// Issues a new access token and a new refresh token (saving the refresh token entry to the store).
+func (issuer *Issuer) issue(refreshToken *RefreshToken, response *requests.Response) (err error) {
+	var now = time.Now()
+
+	var claims = data.GenericMap{
+		"jti":   uuid.NewString(),
+		"sub":   refreshToken.Subject,
+		"iat":   now.Unix(),
+		"exp":   now.Add(issuer.accessTokenTtl).Unix(),
+		"scope": strings.Join(refreshToken.Scopes, " "),
+	}
+
+	claims.Set(issuer.rolesClaim, refreshToken.Roles)
+
+	if issuer.issuer != "" {
+		claims.Set("iss", issuer.issuer)
+	}
+
+	if issuer.audience != "" {
+		claims.Set("aud", issuer.audience)
+	}
+
+	var accessToken string
+
+	if accessToken, err = authenticators.SignJwt(claims, issuer.secret); err != nil {
+		return
+	}
+
+	var randomBytes = make([]byte, 32)
+
+	if _, err = rand.Read(randomBytes); err != nil {
+		return
+	}
+
+	var refreshTokenValue = base64.RawURLEncoding.EncodeToString(randomBytes)
+
+	refreshToken.Hash = hashToken(refreshTokenValue)
+	refreshToken.ExpiresAt = now.Add(issuer.refreshTokenTtl)
+
+	if err = issuer.store.SaveRefreshToken(refreshToken); err != nil {
+		return
+	}
+
+	response.Status = requests.ResourceCreated
+	response.Data.Set("accessToken", accessToken)
+	response.Data.Set("tokenType", "Bearer")
+	response.Data.Set("expiresIn", int64(issuer.accessTokenTtl.Seconds()))
+	response.Data.Set("refreshToken", refreshTokenValue)
+
+	return nil
+}
+
+const (
+	tokensLogTag = "tokens"
+)
+
+func hashToken(token string) string {
+	var tokenHash = sha256.Sum256([]byte(token))
+	return hex.EncodeToString(tokenHash[:])
+}
@@ -0,0 +1,186 @@
+package service
+
+// A node of the route tree. Each node represents a single path part and holds the route that ends on it (if any).
+type routeNode struct {
+	staticChildren map[string]*routeNode
+	typedChildren  []*typedRouteNode
+	variableChild  *routeNode
+	catchAllChild  *routeNode
+	route          *routeInfo
+}
+
+// A child node for variable parts constrained to a parameter type.
+type typedRouteNode struct {
+	*routeNode
+
+	typeName      string
+	parameterType ParameterType
+}
+
+func newRouteNode() *routeNode {
+	return &routeNode{
+		staticChildren: make(map[string]*routeNode),
+	}
+}
+
+// Adds a route to the tree. Routes with optional parts end on more than one node (one for each optional part that
+// may be left out). If another route already ends on any of these nodes it is returned as a conflict and the route is
+// not added.
+func (node *routeNode) insert(parts []routePartInfo, route *routeInfo) (conflict *routeInfo) {
+	var endNodes = make([]*routeNode, 0)
+
+	for _, part := range parts {
+		if part.isOptional {
+			endNodes = append(endNodes, node)
+		}
+
+		node = node.child(part)
+	}
+
+	endNodes = append(endNodes, node)
+
+	for _, endNode := range endNodes {
+		if endNode.route != nil {
+			return endNode.route
+		}
+	}
+
+	for _, endNode := range endNodes {
+		endNode.route = route
+	}
+
+	return nil
+}
+
+// Returns a copy of the tree in which the nodes along a route path are copied (and the other ones shared), so the
+// route can be added to the copy while the tree is in use.
+func (node *routeNode) copyPath(parts []routePartInfo) *routeNode {
+	var nodeCopy = &routeNode{
+		staticChildren: make(map[string]*routeNode, len(node.staticChildren)),
+		typedChildren:  make([]*typedRouteNode, 0, len(node.typedChildren)),
+		variableChild:  node.variableChild,
+		catchAllChild:  node.catchAllChild,
+		route:          node.route,
+	}
+
+	for part, staticChild := range node.staticChildren {
+		nodeCopy.staticChildren[part] = staticChild
+	}
+
+	for _, typedChild := range node.typedChildren {
+		var typedChildCopy = *typedChild
+		nodeCopy.typedChildren = append(nodeCopy.typedChildren, &typedChildCopy)
+	}
+
+	if len(parts) > 0 {
+		if child := nodeCopy.findChild(parts[0]); child != nil {
+			nodeCopy.setChild(parts[0], child.copyPath(parts[1:]))
+		}
+	}
+
+	return nodeCopy
+}
+
+// Returns the child node for a route part, creating it if needed.
+func (node *routeNode) child(part routePartInfo) *routeNode {
+	var child = node.findChild(part)
+
+	if child == nil {
+		child = newRouteNode()
+		node.setChild(part, child)
+	}
+
+	return child
+}
+
+// Returns the child node for a route part, or nil if there is none.
+func (node *routeNode) findChild(part routePartInfo) *routeNode {
+	if part.isCatchAll {
+		return node.catchAllChild
+	}
+
+	if part.isVariable && (part.parameterType != nil) {
+		for _, typedChild := range node.typedChildren {
+			if typedChild.typeName == part.typeName {
+				return typedChild.routeNode
+			}
+		}
+
+		return nil
+	}
+
+	if part.isVariable {
+		return node.variableChild
+	}
+
+	return node.staticChildren[part.part]
+}
+
+// Sets (adding or replacing) the child node for a route part.
+func (node *routeNode) setChild(part routePartInfo, child *routeNode) {
+	if part.isCatchAll {
+		node.catchAllChild = child
+		return
+	}
+
+	if part.isVariable && (part.parameterType != nil) {
+		for _, typedChild := range node.typedChildren {
+			if typedChild.typeName == part.typeName {
+				typedChild.routeNode = child
+				return
+			}
+		}
+
+		node.typedChildren = append(node.typedChildren, &typedRouteNode{
+			routeNode:     child,
+			typeName:      part.typeName,
+			parameterType: part.parameterType,
+		})
+
+		return
+	}
+
+	if part.isVariable {
+		node.variableChild = child
+		return
+	}
+
+	node.staticChildren[part.part] = child
+}
+
+// Looks for the route matching the path parts. Static parts take precedence over typed variable ones (in the order
+// they were added), then untyped variable ones and then catch-all ones. Typed variable parts only match the path
+// parts their parameter type accepts. The search backtracks when a branch does not lead to a route.
+func (node *routeNode) find(pathParts []string) *routeInfo {
+	if len(pathParts) == 0 {
+		return node.route
+	}
+
+	if staticChild, childExists := node.staticChildren[pathParts[0]]; childExists {
+		if route := staticChild.find(pathParts[1:]); route != nil {
+			return route
+		}
+	}
+
+	for _, typedChild := range node.typedChildren {
+		if _, isValid := typedChild.parameterType(pathParts[0]); !isValid {
+			continue
+		}
+
+		if route := typedChild.find(pathParts[1:]); route != nil {
+			return route
+		}
+	}
+
+	if node.variableChild != nil {
+		if route := node.variableChild.find(pathParts[1:]); route != nil {
+			return route
+		}
+	}
+
+	if node.catchAllChild != nil {
+		return node.catchAllChild.route
+	}
+
+	return nil
+}